@@ -4,41 +4,51 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 	f "github.com/soffa-projects/foundation-go/core"
 	"github.com/soffa-projects/foundation-go/h"
 	"github.com/soffa-projects/foundation-go/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // AsynqQueue implements EmailQueue, PhoneQueue, and JobInspector using Asynq
 type AsynqQueue struct {
+	instrumentation
 	client    *asynq.Client
 	inspector *asynq.Inspector
 }
 
 // NewAsynqQueue creates a new Asynq queue client
 func NewAsynqQueueProvider(redisURL string) (*AsynqQueue, error) {
+	opt, err := asynqRedisOpt(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := asynq.NewClient(opt)
+	inspector := asynq.NewInspector(opt)
+	return &AsynqQueue{
+		client:    client,
+		inspector: inspector,
+	}, nil
+}
 
+func asynqRedisOpt(redisURL string) (asynq.RedisClientOpt, error) {
 	cfg, err := h.ParseUrl(redisURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %v", err)
+		return asynq.RedisClientOpt{}, fmt.Errorf("failed to parse Redis URL: %v", err)
 	}
 	db := 0
 	if cfg.HasQueryParam("db") {
 		db = int(cfg.Query("db").(int64))
 	}
-	opt := asynq.RedisClientOpt{
+	return asynq.RedisClientOpt{
 		Addr:     cfg.Host,
 		Username: cfg.User,
 		Password: cfg.Password,
 		DB:       db,
-	}
-	client := asynq.NewClient(opt)
-	inspector := asynq.NewInspector(opt)
-	return &AsynqQueue{
-		client:    client,
-		inspector: inspector,
 	}, nil
 }
 
@@ -54,15 +64,21 @@ func MustNewAsynqQueueProvider(redisURL string) *AsynqQueue {
 // to avoid import cycles
 
 // Enqueue is the public implementation for enqueueing jobs (implements QueueClient interface)
-func (q *AsynqQueue) Enqueue(ctx context.Context, jobType f.JobType, data any) (string, error) {
+func (q *AsynqQueue) Enqueue(ctx context.Context, jobType f.JobType, data any) (_ string, err error) {
+	ctx, span := tracer().Start(ctx, "enqueue "+jobType,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("job.type", jobType)),
+	)
+	defer func() { endSpan(span, err) }()
+
 	// Serialize data to JSON
 	payload, err := json.Marshal(data)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal job data: %w", err)
 	}
 
-	// Create task
-	task := asynq.NewTask(jobType, payload)
+	// Create task, the worker continues the trace from the payload
+	task := asynq.NewTask(jobType, injectTrace(ctx, payload))
 
 	// Enqueue task
 	info, err := q.client.EnqueueContext(ctx, task,
@@ -70,8 +86,11 @@ func (q *AsynqQueue) Enqueue(ctx context.Context, jobType f.JobType, data any) (
 		asynq.MaxRetry(3),
 	)
 	if err != nil {
+		q.metrics().queueEnqueued.Inc(jobType, "error")
 		return "", fmt.Errorf("failed to enqueue task: %w", err)
 	}
+	q.metrics().queueEnqueued.Inc(jobType, "ok")
+	span.SetAttributes(attribute.String("job.id", info.ID))
 
 	log.Info("job enqueued id=%s (%s)", info.ID, jobType)
 
@@ -124,6 +143,10 @@ func (q *AsynqQueue) GetJobStatus(ctx context.Context, jobID string) (*f.JobStat
 	return nil, fmt.Errorf("job not found: %s", jobID)
 }
 
+func (q *AsynqQueue) Ping() error {
+	return q.client.Ping()
+}
+
 func (q *AsynqQueue) Close() error {
 	return q.client.Close()
 }
@@ -147,3 +170,105 @@ func mapTaskState(state asynq.TaskState) string {
 		return "unknown"
 	}
 }
+
+// ------------------------------------------------------------------------------------------------------------------
+// ASYNQ WORKER IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+// AsynqWorker processes jobs enqueued by AsynqQueue
+type AsynqWorker struct {
+	f.QueueWorker
+	instrumentation
+	server   *asynq.Server
+	mux      *asynq.ServeMux
+	reporter f.ErrorReporter
+}
+
+// NewAsynqWorker creates a worker, running jobs get up to shutdownTimeout to complete
+// on shutdown before they are pushed back to the queue.
+func NewAsynqWorker(redisURL string, reporter f.ErrorReporter, shutdownTimeout time.Duration) (*AsynqWorker, error) {
+	opt, err := asynqRedisOpt(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	server := asynq.NewServer(opt, asynq.Config{
+		Queues:          map[string]int{"default": 1},
+		ShutdownTimeout: shutdownTimeout,
+	})
+	return &AsynqWorker{
+		server:   server,
+		mux:      asynq.NewServeMux(),
+		reporter: reporter,
+	}, nil
+}
+
+func (w *AsynqWorker) Handle(jobType f.JobType, handler f.JobHandler) {
+	w.mux.HandleFunc(jobType, func(ctx context.Context, task *asynq.Task) error {
+		id, _ := asynq.GetTaskID(ctx)
+		return runJob(ctx, w.metrics(), w.reporter, f.Job{ID: id, Type: task.Type(), Payload: task.Payload()}, handler)
+	})
+}
+
+func (w *AsynqWorker) Ping() error {
+	return w.server.Ping()
+}
+
+func (w *AsynqWorker) Start() error {
+	log.Info("[queue] starting asynq worker")
+	return w.server.Start(w.mux)
+}
+
+func (w *AsynqWorker) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.server.Shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Info("[queue] asynq worker stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("queue worker shutdown: %w", ctx.Err())
+	}
+}
+
+// runJob runs handler, turning panics into errors so the job is retried, and reports
+// every failure.
+func runJob(ctx context.Context, m *instruments, reporter f.ErrorReporter, job f.Job, handler f.JobHandler) (err error) {
+	ctx = context.WithValue(ctx, f.RouteKey{}, "job:"+job.Type)
+	if job.ID != "" {
+		ctx = context.WithValue(ctx, f.RequestIdKey{}, job.ID)
+	}
+	ctx, job.Payload = extractTrace(ctx, job.Payload)
+	ctx, span := tracer().Start(ctx, "job "+job.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("job.type", job.Type),
+			attribute.String("job.id", job.ID),
+		),
+	)
+	start := time.Now()
+	defer func() {
+		if value := recover(); value != nil {
+			if reporter != nil {
+				reporter.CapturePanic(ctx, value)
+			}
+			err = fmt.Errorf("job %s panicked: %v", job.Type, value)
+		}
+		endSpan(span, err)
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		m.queueJobs.Inc(job.Type, status)
+		m.queueJobDuration.Observe(seconds(start), job.Type)
+	}()
+	if err = handler(ctx, job); err != nil {
+		log.Error("[queue] job %s (%s) failed: %v", job.ID, job.Type, err)
+		if reporter != nil {
+			reporter.CaptureError(ctx, err)
+		}
+	}
+	return err
+}
@@ -42,6 +42,7 @@ func MustNewCacheProvider(provider string) f.CacheProvider {
 
 type RedisCacheProvider struct {
 	f.CacheProvider
+	instrumentation
 	client *redis.Client
 }
 
@@ -68,7 +69,13 @@ func (p *RedisCacheProvider) Set(ctx context.Context, key string, value any, dur
 }
 
 func (p *RedisCacheProvider) Get(ctx context.Context, key string) (any, error) {
-	return p.client.Get(ctx, key).Result()
+	value, err := p.client.Get(ctx, key).Result()
+	if err == nil {
+		p.metrics().cacheRequests.Inc("hit")
+	} else if err == redis.Nil {
+		p.metrics().cacheRequests.Inc("miss")
+	}
+	return value, err
 }
 
 func (p *RedisCacheProvider) Ping() error {
@@ -81,11 +88,12 @@ func (p *RedisCacheProvider) Ping() error {
 
 type InMemoryCacheProvider struct {
 	f.CacheProvider
+	instrumentation
 	cache map[string]any
 }
 
 func NewInMemoryCacheProvider() f.CacheProvider {
-	return InMemoryCacheProvider{
+	return &InMemoryCacheProvider{
 		cache: make(map[string]any),
 	}
 }
@@ -108,5 +116,11 @@ func (p InMemoryCacheProvider) Set(ctx context.Context, key string, value any, d
 }
 
 func (p InMemoryCacheProvider) Get(ctx context.Context, key string) (any, error) {
-	return p.cache[key], nil
+	value, ok := p.cache[key]
+	if ok {
+		p.metrics().cacheRequests.Inc("hit")
+	} else {
+		p.metrics().cacheRequests.Inc("miss")
+	}
+	return value, nil
 }
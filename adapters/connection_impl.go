@@ -5,19 +5,21 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io/fs"
 	"net/url"
+	"reflect"
 	"strings"
 
-	"github.com/pressly/goose/v3"
 	f "github.com/soffa-projects/foundation-go/core"
 	"github.com/soffa-projects/foundation-go/h"
-	"github.com/soffa-projects/foundation-go/log"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/uptrace/bun/driver/sqliteshim"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type connectionImpl struct {
@@ -31,6 +33,15 @@ type connectionImpl struct {
 	schema      string
 	initialized bool
 	transaction bool
+	// discriminator scopes the TenantOwned entities to the tenant of the context
+	discriminator    bool
+	rowLevelSecurity bool
+	tenantSlug       string
+	// shared connections are views of the pool of the default connection
+	shared   bool
+	replicas *replicaSet
+	// metrics are the built-in metrics recorded by the queries of the connection
+	metrics *instruments
 	//tx          bool
 }
 
@@ -39,7 +50,7 @@ func NewConnection(databaseUrl string) (f.Connection, error) {
 		Url:     databaseUrl,
 		Default: true,
 	}
-	err := cnx.configure(nil, "")
+	err := cnx.configure(context.Background(), migrationSources{}, "")
 	if err != nil {
 		return nil, err
 	}
@@ -51,180 +62,347 @@ func (t connectionImpl) Ping() error {
 	return err
 }
 
-func (t connectionImpl) Tx(ctx context.Context) (f.Connection, error) {
-	if t.db == nil {
-		return nil, errors.New("database not initialized")
+// Close releases the connection pool, it is a no-op for transactions and shared connections
+func (t connectionImpl) Close() error {
+	if t.shared {
+		return nil
 	}
-	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{
+	if db, ok := t.db.(*bun.DB); ok {
+		if t.replicas != nil {
+			return errors.Join(db.Close(), t.replicas.Close())
+		}
+		return db.Close()
+	}
+	return nil
+}
+
+func (t connectionImpl) Tx(ctx context.Context) (f.Connection, error) {
+	return t.beginTx(ctx, &sql.TxOptions{
 		ReadOnly:  false,
 		Isolation: sql.LevelDefault,
 	})
-	if t.schema != "" && t.dialect == "postgres" {
-		t.SetSchema(t.schema)
+}
+
+// beginTx begins a transaction, a savepoint of the current one when t is a transaction,
+// which fails when opts asks for another isolation level or a read only mode.
+func (t connectionImpl) beginTx(ctx context.Context, opts *sql.TxOptions) (f.Connection, error) {
+	if t.db == nil {
+		return nil, errors.New("database not initialized")
 	}
+	if t.transaction {
+		// a savepoint runs with the isolation and the mode of its transaction
+		if opts != nil && (opts.Isolation != sql.LevelDefault || opts.ReadOnly) {
+			return nil, fmt.Errorf("a savepoint of transaction %s cannot change its options (isolation: %s, read only: %t)", t.Id, opts.Isolation, opts.ReadOnly)
+		}
+		// the schema and the tenant of the transaction are already set
+		var sp bun.IDB
+		var err error
+		switch db := t.db.(type) {
+		case migrationTx:
+			sp, err = db.savepoint(ctx)
+		case migrationSavepoint:
+			sp, err = db.savepoint(ctx)
+		default:
+			sp, err = t.db.BeginTx(ctx, opts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %v", err)
+		}
+		t.Id = fmt.Sprintf("%s-sp-%s", t.Id, h.RandomString(5))
+		t.db = sp
+		return t, nil
+	}
+	tx, err := t.db.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %v", err)
 	}
+	if err := t.setRowLevelTenant(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to set the tenant of the transaction: %v", err)
+	}
 	return connectionImpl{
-		Default:     t.Default,
-		Url:         t.Url,
-		Id:          fmt.Sprintf("%s-tx-%s", t.Id, h.RandomString(5)),
-		dialect:     t.dialect,
-		db:          tx,
-		schema:      t.schema,
-		initialized: t.initialized,
-		transaction: true,
+		Default:          t.Default,
+		Url:              t.Url,
+		Id:               fmt.Sprintf("%s-tx-%s", t.Id, h.RandomString(5)),
+		dialect:          t.dialect,
+		db:               tx,
+		schema:           t.schema,
+		initialized:      t.initialized,
+		transaction:      true,
+		discriminator:    t.discriminator,
+		rowLevelSecurity: t.rowLevelSecurity,
+		tenantSlug:       t.tenantSlug,
 	}, nil
 }
 
+func (t connectionImpl) Transaction(ctx context.Context, fn func(tx f.Connection) error) error {
+	tx, err := t.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	return runInTx(tx, fn)
+}
+
+// dbTx is implemented by bun.Tx and by the savepoints of migration transactions
+type dbTx interface {
+	Commit() error
+	Rollback() error
+}
+
 func (t connectionImpl) Commit() error {
-	if tx, ok := t.db.(bun.Tx); ok {
+	if tx, ok := t.db.(dbTx); ok {
 		return tx.Commit()
 	}
 	return nil
 }
 
 func (t connectionImpl) Rollback() error {
-	if tx, ok := t.db.(bun.Tx); ok {
+	if tx, ok := t.db.(dbTx); ok {
 		return tx.Rollback()
 	}
 	return nil
 }
 
-func (t *connectionImpl) configure(migrationsFS []fs.FS, prefix string) error {
-	var (
-		sqldb   *sql.DB
-		db      *bun.DB
-		err     error
-		dialect string
-	)
-
-	if strings.HasPrefix(t.Url, "postgres://") || strings.HasPrefix(t.Url, "postgresql://") {
-		u, err := url.Parse(t.Url)
-		if err != nil {
-			return err
-		}
-		t.schema = u.Query().Get("schema")
-		if t.schema != "" {
-			t.Url, err = h.RemoveParamFromUrl(t.Url, "schema")
-			if err != nil {
-				return err
-			}
-		}
-
-		sqldb = sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(t.Url)))
-		db = bun.NewDB(sqldb, pgdialect.New())
-		dialect = "postgres"
-
-	} else if strings.HasPrefix(t.Url, "sqlite://") || strings.HasPrefix(t.Url, "file:") {
-		dialect = "sqlite3"
-		sqliteDSN := strings.Replace(t.Url, "sqlite://", "", 1)
-		sqldb, err = sql.Open(sqliteshim.ShimName, sqliteDSN)
-		if err != nil {
-			return err
-		}
-		db = bun.NewDB(sqldb, sqlitedialect.New())
-		_, err = db.Exec("PRAGMA foreign_keys = ON;")
-		if err != nil {
+func (t *connectionImpl) configure(ctx context.Context, migrations migrationSources, prefix string) error {
+	var err error
+	t.Url, t.schema, err = splitSchema(t.Url)
+	if err != nil {
+		return err
+	}
+	if t.schema != "" && isMySQLUrl(t.Url) {
+		if err := createMySQLDatabase(ctx, t.Url, t.schema); err != nil {
 			return err
 		}
-	} else {
-		return fmt.Errorf("unsupported database url: %s", t.Url)
 	}
+	db, dialect, err := openDB(t.Url, t.schema)
+	if err != nil {
+		return err
+	}
+	db.AddQueryHook(queryHook{tenant: t.Id, dialect: dialect, metrics: t.metrics})
 	t.db = db
 	t.dialect = dialect
 
-	var paths []string
-	if t.Default {
-		paths = []string{"resources/db/migrations/shared", "db/migrations/shared"}
-	} else {
-		paths = []string{"resources/db/migrations/tenant", "db/migrations/tenant"}
-	}
-	changeLogTable := "database_changelog"
-	if prefix != "" {
-		changeLogTable = fmt.Sprintf("%s_%s", strings.TrimSuffix(prefix, "_"), changeLogTable)
-	}
-	if len(migrationsFS) > 0 {
-		for _, dir := range migrationsFS {
-			for _, path := range paths {
-				if err := t.migrate(changeLogTable, dir, path); err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	if t.schema != "" {
-		err := t.SetSchema(t.schema)
-		if err != nil {
+	if !migrations.empty() {
+		if err := t.migrate(ctx, migrations, prefix); err != nil {
 			return err
 		}
 	}
+
 	t.initialized = true
 	return nil
 }
 
-func (t connectionImpl) migrate(changeLogTable string, dir fs.FS, path string) error {
+// hasSchemas reports whether the tenants of databaseUrl can be isolated in schemas with
+// the schema strategy, the databases of the server with mysql
+func hasSchemas(databaseUrl string) bool {
+	return strings.HasPrefix(databaseUrl, "postgres://") || strings.HasPrefix(databaseUrl, "postgresql://") || isMySQLUrl(databaseUrl)
+}
 
-	if dir == nil {
-		return nil
-	}
+func isMySQLUrl(databaseUrl string) bool {
+	return strings.HasPrefix(databaseUrl, "mysql://")
+}
 
-	// Check if there are any *.sql files in the migration directory
-	entries, err := fs.ReadDir(dir, path)
-	if err != nil {
-		return nil
-	}
-	if len(entries) == 0 {
-		return nil
+// splitSchema removes the schema parameter of postgres and mysql urls and returns it apart
+func splitSchema(databaseUrl string) (string, string, error) {
+	if !hasSchemas(databaseUrl) {
+		return databaseUrl, "", nil
 	}
-	hasSQL := false
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
-			hasSQL = true
-			break
-		}
+	u, err := url.Parse(databaseUrl)
+	if err != nil {
+		return "", "", err
 	}
-	if !hasSQL {
-		return nil
+	schema := u.Query().Get("schema")
+	if schema == "" {
+		return databaseUrl, "", nil
 	}
+	databaseUrl, err = h.RemoveParamFromUrl(databaseUrl, "schema")
+	return databaseUrl, schema, err
+}
 
-	goose.SetBaseFS(dir)
-	ctx := context.Background()
-
-	if err := goose.SetDialect(t.dialect); err != nil {
-		return fmt.Errorf("failed to set dialect: %v", err)
-	}
-	goose.SetTableName(changeLogTable)
+// openDB opens the pool of databaseUrl and returns the dialect of its database, schema
+// being the postgres schema or the database of a mysql server to use
+func openDB(databaseUrl string, schema string) (*bun.DB, string, error) {
+	switch {
+	case strings.HasPrefix(databaseUrl, "postgres://") || strings.HasPrefix(databaseUrl, "postgresql://"):
+		if schema != "" {
+			// pgdriver sets the unknown parameters of the url on every connection of the pool
+			databaseUrl = h.AppendParamToUrl(databaseUrl, "search_path", schema)
+		}
+		sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(databaseUrl)))
+		return bun.NewDB(sqldb, pgdialect.New()), "postgres", nil
 
-	if t.dialect == "postgres" {
-		schema := "public"
-		if t.schema != "" {
-			schema = t.schema
+	case isMySQLUrl(databaseUrl):
+		if schema != "" {
+			databaseUrl = h.AppendParamToUrl(databaseUrl, "schema", schema)
 		}
-		if _, err := t.db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", schema)); err != nil {
-			return fmt.Errorf("failed to create schema %s: %v", schema, err)
+		dsn, err := h.MySQLDSN(databaseUrl)
+		if err != nil {
+			return nil, "", err
 		}
-		if _, err := t.db.ExecContext(ctx, fmt.Sprintf("SET search_path TO %s", schema)); err != nil {
-			return fmt.Errorf("failed to set search path %s: %v", schema, err)
+		sqldb, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, "", err
 		}
+		return bun.NewDB(sqldb, mysqldialect.New()), "mysql", nil
+
+	case strings.HasPrefix(databaseUrl, "sqlite://") || strings.HasPrefix(databaseUrl, "file:"):
+		sqliteDSN := strings.Replace(databaseUrl, "sqlite://", "", 1)
+		sqldb, err := sql.Open(sqliteshim.ShimName, sqliteDSN)
+		if err != nil {
+			return nil, "", err
+		}
+		db := bun.NewDB(sqldb, sqlitedialect.New())
+		if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil {
+			_ = db.Close()
+			return nil, "", err
+		}
+		return db, "sqlite3", nil
 	}
-	if err := goose.Up((t.db.(*bun.DB)).DB, path, goose.WithAllowMissing()); err != nil {
-		return fmt.Errorf("failed to run migrations for %s: %v", t.Id, err)
-	}
+	return nil, "", fmt.Errorf("unsupported database url: %s", databaseUrl)
+}
 
-	log.Info("migrations completed for tenant: %s", t.Id)
+// createMySQLDatabase creates the database of a tenant on the mysql server of databaseUrl
+func createMySQLDatabase(ctx context.Context, databaseUrl string, database string) error {
+	db, _, err := openDB(databaseUrl, "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS ?", bun.Ident(database)); err != nil {
+		return fmt.Errorf("failed to create database %s: %v", database, err)
+	}
 	return nil
 }
 
 func (t connectionImpl) Insert(ctx context.Context, entity f.Entity) error {
-	_, err := t.db.NewInsert().Model(entity).Exec(ctx)
-	return err
+	return t.rowLevelScoped(ctx, false, func(t connectionImpl) error {
+		if _, err := t.ownEntity(ctx, entity); err != nil {
+			return err
+		}
+		beforeInsert(ctx, entity)
+		_, err := t.db.NewInsert().Model(entity).Exec(ctx)
+		return err
+	})
 }
 
 func (t connectionImpl) InsertBatch(ctx context.Context, entities f.Entity) error {
-	_, err := t.db.NewInsert().Model(entities).Exec(ctx)
-	return err
+	return t.rowLevelScoped(ctx, false, func(t connectionImpl) error {
+		if _, err := t.ownEntity(ctx, entities); err != nil {
+			return err
+		}
+		beforeInsert(ctx, entities)
+		_, err := t.db.NewInsert().Model(entities).Exec(ctx)
+		return err
+	})
+}
+
+func (t connectionImpl) Upsert(ctx context.Context, entity f.Entity, conflictColumns ...string) error {
+	return t.rowLevelScoped(ctx, false, func(t connectionImpl) error {
+		if len(conflictColumns) == 0 {
+			for _, pk := range t.db.Dialect().Tables().Get(reflect.TypeOf(entity)).PKs {
+				conflictColumns = append(conflictColumns, pk.Name)
+			}
+		}
+		tenant, err := t.ownEntity(ctx, entity)
+		if err != nil {
+			return err
+		}
+		beforeInsert(ctx, entity)
+		if t.dialect == "mysql" {
+			return t.upsertMySQL(ctx, entity, tenant)
+		}
+		versioned, isVersioned := entity.(f.VersionedEntity)
+		q := t.db.NewInsert().
+			Model(entity).
+			On(fmt.Sprintf("CONFLICT (%s) DO UPDATE", strings.Join(conflictColumns, ", ")))
+		// keep the creation timestamps and author of existing rows
+		for _, column := range upsertColumns(t.db, entity) {
+			if isVersioned && column == versionColumn {
+				q = q.Set("? = ?TableAlias.? + 1", bun.Ident(column), bun.Ident(column))
+				continue
+			}
+			q = q.Set("? = EXCLUDED.?", bun.Ident(column), bun.Ident(column))
+		}
+		if tenant != "" {
+			// never overwrite the conflicting row of another tenant
+			q = q.Where(tenantFilter, tenant)
+		}
+		if !isVersioned {
+			_, err = q.Exec(ctx)
+			return err
+		}
+		// a conflicting row is only overwritten at the version of entity, which then gets
+		// the stored one
+		current := versioned.GetVersion()
+		res, err := q.
+			Where("?TableAlias.? = EXCLUDED.?", bun.Ident(versionColumn), bun.Ident(versionColumn)).
+			Returning("?", bun.Ident(versionColumn)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		upserted, err := res.RowsAffected()
+		if err == nil && upserted == 0 {
+			err = staleVersion(current)
+		}
+		if err != nil {
+			versioned.SetVersion(current)
+		}
+		return err
+	})
+}
+
+// upsertMySQL updates the row conflicting on any unique key of entity, mysql having no
+// conflict target
+func (t connectionImpl) upsertMySQL(ctx context.Context, entity f.Entity, tenant string) error {
+	versioned, isVersioned := entity.(f.VersionedEntity)
+	// the conflicting row is only overwritten when it belongs to the tenant, and is at
+	// the version of entity for Versioned entities
+	var guards []string
+	var guardArgs []any
+	if tenant != "" {
+		guards = append(guards, "? = ?")
+		guardArgs = append(guardArgs, bun.Ident(tenantColumn), tenant)
+	}
+	if isVersioned {
+		guards = append(guards, "? = VALUES(?)")
+		guardArgs = append(guardArgs, bun.Ident(versionColumn), bun.Ident(versionColumn))
+	}
+	guard := strings.Join(guards, " AND ")
+	q := t.db.NewInsert().Model(entity).On("DUPLICATE KEY UPDATE")
+	for _, column := range upsertColumns(t.db, entity) {
+		switch {
+		case guard == "":
+			q = q.Set("? = VALUES(?)", bun.Ident(column), bun.Ident(column))
+		case tenant != "" && column == tenantColumn, isVersioned && column == versionColumn:
+			// the tenant column is left as is and the version set last for the guards
+			// of the other columns to see the stored values
+		default:
+			args := append([]any{bun.Ident(column)}, guardArgs...)
+			q = q.Set("? = IF("+guard+", VALUES(?), ?)", append(args, bun.Ident(column), bun.Ident(column))...)
+		}
+	}
+	if !isVersioned {
+		_, err := q.Exec(ctx)
+		return err
+	}
+	args := append([]any{bun.Ident(versionColumn)}, guardArgs...)
+	q = q.Set("? = IF("+guard+", ? + 1, ?)", append(args, bun.Ident(versionColumn), bun.Ident(versionColumn))...)
+	current := versioned.GetVersion()
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	// one row affected for an insert, two for an update and none for a stale version
+	switch upserted, err := res.RowsAffected(); {
+	case err != nil:
+		return err
+	case upserted == 0:
+		return staleVersion(current)
+	case upserted == 2:
+		versioned.SetVersion(current + 1)
+	}
+	return nil
 }
 
 func (t connectionImpl) SetSchema(schema string) error {
@@ -237,97 +415,161 @@ func (t connectionImpl) SetSchema(schema string) error {
 }
 
 func (t connectionImpl) Update(ctx context.Context, entity f.Entity, columns ...string) error {
-	_, err := t.db.
-		NewUpdate().
-		Model(entity).
-		Column(columns...).
-		WherePK().
-		Exec(ctx)
-	return err
+	return t.rowLevelScoped(ctx, false, func(t connectionImpl) error {
+		tenant, err := t.ownEntity(ctx, entity)
+		if err != nil {
+			return err
+		}
+		return updateEntity(ctx, t.db, entity, tenant, columns...)
+	})
 }
 
 func (t connectionImpl) UpdateBy(ctx context.Context, entity f.Entity, columns []string, where string, args ...any) (int64, error) {
-	res, err := t.db.
-		NewUpdate().
-		Model(entity).
-		Column(columns...).
-		Where(where, args...).
-		Exec(ctx)
-	if err != nil {
-		return 0, err
-	}
-	return res.RowsAffected()
+	return rowLevelScopedResult(ctx, t, false, func(t connectionImpl) (int64, error) {
+		tenant, err := t.ownEntity(ctx, entity)
+		if err != nil {
+			return 0, err
+		}
+		return updateRows(ctx, t.db, entity, tenant, columns, where, args...)
+	})
 }
 
 func (t connectionImpl) Delete(ctx context.Context, entity f.Entity) error {
-	_, err := t.db.NewDelete().Model(entity).WherePK().Exec(ctx)
-	return err
+	return t.rowLevelScoped(ctx, false, func(t connectionImpl) error {
+		q, err := t.newDelete(ctx, entity)
+		if err != nil {
+			return err
+		}
+		_, err = q.Model(entity).WherePK().Exec(ctx)
+		return err
+	})
 }
 
 func (t connectionImpl) FindBy(ctx context.Context, entity f.Entity, where string, args ...any) (bool, error) {
-	err := t.db.NewSelect().Model(entity).Where(where, args...).Scan(ctx)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return true, nil
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (bool, error) {
+		q, err := t.newSelect(ctx, entity)
+		if err != nil {
+			return false, err
 		}
-		return false, err
-	}
-	return false, nil
+		err = q.Model(entity).Where(where, args...).Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
 }
 
 func (t connectionImpl) ExistsBy(ctx context.Context, entity f.Entity, where string, args ...any) (bool, error) {
-	err := t.db.NewSelect().Model(entity).Where(where, args...).Scan(ctx)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (bool, error) {
+		q, err := t.newSelect(ctx, entity)
+		if err != nil {
+			return false, err
 		}
-		return false, err
-	}
-	return true, nil
+		err = q.Model(entity).Where(where, args...).Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
 }
 
 func (t connectionImpl) Count(ctx context.Context, entity f.Entity) (int, error) {
-	count, err := t.db.NewSelect().
-		Model(entity).
-		Count(ctx)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return 0, nil
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (int, error) {
+		q, err := t.newSelect(ctx, entity)
+		if err != nil {
+			return 0, err
 		}
-	}
-	return count, err
+		count, err := q.
+			Model(entity).
+			Count(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, nil
+			}
+		}
+		return count, err
+	})
 }
 
 func (t connectionImpl) CountBy(ctx context.Context, entity f.Entity, where string, args ...any) (int, error) {
-	return countByJoin(ctx, t.db.NewSelect(), entity, "", where, args...)
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (int, error) {
+		q, err := t.newSelect(ctx, entity)
+		if err != nil {
+			return 0, err
+		}
+		return countByJoin(ctx, q, entity, "", where, args...)
+	})
 }
 
 func (t connectionImpl) DeleteBy(ctx context.Context, entity f.Entity, where string, args ...any) error {
-	_, err := t.db.NewDelete().Model(entity).Where(where, args...).Exec(ctx)
-	return err
+	return t.rowLevelScoped(ctx, false, func(t connectionImpl) error {
+		q, err := t.newDelete(ctx, entity)
+		if err != nil {
+			return err
+		}
+		_, err = q.Model(entity).Where(where, args...).Exec(ctx)
+		return err
+	})
 }
 
 func (t connectionImpl) FindByJoin(ctx context.Context, model f.Entity, join string, where string, args ...any) (bool, error) {
-	err := t.db.NewSelect().
-		Model(model).
-		Join(join).
-		Where(where, args...).
-		Scan(ctx)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return true, nil
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (bool, error) {
+		q, err := t.newSelect(ctx, model)
+		if err != nil {
+			return false, err
 		}
-		return false, err
-	}
-	return false, nil
+		err = q.
+			Model(model).
+			Join(join).
+			Where(where, args...).
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
 }
 
 func (t connectionImpl) Query(ctx context.Context, model f.Entity, opts ...f.QueryOpts) (bool, error) {
-	return Query(ctx, t.db.NewSelect(), model, opts...)
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (bool, error) {
+		opts, err := t.scopedOpts(ctx, model, opts)
+		if err != nil {
+			return false, err
+		}
+		return Query(ctx, t.reader(ctx).NewSelect(), model, opts...)
+	})
+}
+
+func (t connectionImpl) Paginate(ctx context.Context, models f.Entity, req f.PageRequest, opts ...f.QueryOpts) (f.PageInfo, error) {
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (f.PageInfo, error) {
+		opts, err := t.scopedOpts(ctx, models, opts)
+		if err != nil {
+			return f.PageInfo{}, err
+		}
+		if req.Mode == f.CursorPaging {
+			return paginateCursor(ctx, t.reader(ctx), models, req, opts...)
+		}
+		return paginateOffset(ctx, t.reader(ctx), models, req, opts...)
+	})
 }
 
 func (t connectionImpl) CountByJoin(ctx context.Context, model f.Entity, join string, where string, args ...any) (int, error) {
-	return countByJoin(ctx, t.db.NewSelect(), model, join, where, args...)
+	return rowLevelScopedResult(ctx, t, true, func(t connectionImpl) (int, error) {
+		q, err := t.newSelect(ctx, model)
+		if err != nil {
+			return 0, err
+		}
+		return countByJoin(ctx, q, model, join, where, args...)
+	})
 }
 
 func (t connectionImpl) DatabaseUrl() string {
@@ -353,11 +595,28 @@ func countByJoin(ctx context.Context, query *bun.SelectQuery, model f.Entity, jo
 }
 
 func Query(ctx context.Context, query *bun.SelectQuery, model f.Entity, options ...f.QueryOpts) (bool, error) {
+	q, err := buildQuery(query, model, options...)
+	if err != nil {
+		return false, err
+	}
+	if err := q.Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+func buildQuery(query *bun.SelectQuery, model f.Entity, options ...f.QueryOpts) (*bun.SelectQuery, error) {
 	q := query.Model(model)
 	for _, opts := range options {
 		if opts.Columns != "" {
 			q = q.ColumnExpr(opts.Columns)
 		}
+		if opts.WithDeleted {
+			q = q.WhereAllWithDeleted()
+		}
 		if len(opts.Joins) > 0 {
 			for _, join := range opts.Joins {
 				q = q.Join(join)
@@ -369,6 +628,11 @@ func Query(ctx context.Context, query *bun.SelectQuery, model f.Entity, options
 		if opts.OrderBy != "" {
 			q = q.Order(opts.OrderBy)
 		}
+		var err error
+		if q, err = applyFilter(q, opts.Filter); err != nil {
+			return nil, err
+		}
+		q = applySort(q, opts.Sort)
 		if opts.Limit > 0 {
 			q = q.Limit(opts.Limit)
 		}
@@ -376,11 +640,54 @@ func Query(ctx context.Context, query *bun.SelectQuery, model f.Entity, options
 			q = q.Offset(opts.Offset)
 		}
 	}
-	if err := q.Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return true, nil
+	return q, nil
+}
+
+// queryHook traces every query and records its duration and failures
+type queryHook struct {
+	tenant  string
+	dialect string
+	metrics *instruments
+}
+
+func (h queryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	operation := event.Operation()
+	ctx, _ = tracer().Start(ctx, "db "+strings.ToLower(operation),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameKey.String(h.dialect),
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(event.Query),
+			attribute.String("tenant", h.tenantId()),
+		),
+	)
+	return ctx
+}
+
+func (h queryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	tenant := h.tenantId()
+	operation := strings.ToLower(event.Operation())
+	m := h.metrics
+	if m == nil {
+		m = noopInstruments
+	}
+	m.dbQueryDuration.Observe(seconds(event.StartTime), tenant, operation)
+	failed := event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows)
+	if failed {
+		m.dbQueryErrors.Inc(tenant, operation)
+	}
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		if failed {
+			endSpan(span, event.Err)
+		} else {
+			span.End()
 		}
-		return false, err
 	}
-	return false, nil
+}
+
+func (h queryHook) tenantId() string {
+	if h.tenant == "" {
+		return _defaultTenantId
+	}
+	return h.tenant
 }
@@ -0,0 +1,236 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/feature"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// DISCRIMINATOR STRATEGY
+// ------------------------------------------------------------------------------------------------------------------
+
+const (
+	tenantColumn = "tenant_id"
+	tenantFilter = "?TableAlias.tenant_id = ?"
+	// tenantSetting is the postgres setting read by the row level security policies
+	tenantSetting = "app.tenant_id"
+	// _sharedTenantsId identifies the tenant tables of the discriminator strategy in the
+	// migrations of the default database
+	_sharedTenantsId = "tenants"
+)
+
+var tenantOwnedType = reflect.TypeFor[f.TenantOwnedEntity]()
+
+// isTenantOwned reports whether model, an entity or a pointer to a slice of entities,
+// embeds f.TenantOwned
+func isTenantOwned(model f.Entity) bool {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	return typ != nil && reflect.PointerTo(typ).Implements(tenantOwnedType)
+}
+
+// tenantScope returns the tenant the rows of model are restricted to, "" when t does not
+// use the discriminator strategy or model is not tenant owned.
+func (t connectionImpl) tenantScope(ctx context.Context, model f.Entity) (string, error) {
+	if !t.discriminator || !isTenantOwned(model) {
+		return "", nil
+	}
+	tenant := t.currentTenant(ctx)
+	if tenant == "" {
+		return "", errors.New(http.StatusInternalServerError, errors.CodeTechnical, fmt.Sprintf("%v: %T", f.ErrNoTenant, model)).WithCause(f.ErrNoTenant)
+	}
+	return tenant, nil
+}
+
+// currentTenant returns the tenant id of ctx, the tenants addressed by slug being
+// resolved with the connection of the tenant.
+func (t connectionImpl) currentTenant(ctx context.Context) string {
+	tenant := f.CurrentTenant(ctx)
+	if t.tenantSlug != "" && tenant == t.tenantSlug {
+		return t.Id
+	}
+	return tenant
+}
+
+// ownEntity stamps the tenant of ctx on entity, a single entity or a pointer to a slice of them
+func (t connectionImpl) ownEntity(ctx context.Context, entity f.Entity) (string, error) {
+	tenant, err := t.tenantScope(ctx, entity)
+	if err != nil || tenant == "" {
+		return tenant, err
+	}
+	forEachEntity(entity, func(e any) {
+		if owned, ok := e.(f.TenantOwnedEntity); ok {
+			owned.SetTenantId(tenant)
+		}
+	})
+	return tenant, nil
+}
+
+// scopedOpts appends the tenant filter of model to opts
+func (t connectionImpl) scopedOpts(ctx context.Context, model f.Entity, opts []f.QueryOpts) ([]f.QueryOpts, error) {
+	tenant, err := t.tenantScope(ctx, model)
+	if err != nil || tenant == "" {
+		return opts, err
+	}
+	return append(opts, f.QueryOpts{Where: tenantFilter, Args: []any{tenant}}), nil
+}
+
+// newSelect returns a select query restricted to the rows of the tenant of ctx, run by
+// a replica outside transactions
+func (t connectionImpl) newSelect(ctx context.Context, model f.Entity) (*bun.SelectQuery, error) {
+	q := t.reader(ctx).NewSelect()
+	tenant, err := t.tenantScope(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if tenant != "" {
+		q = q.Where(tenantFilter, tenant)
+	}
+	return q, nil
+}
+
+// newDelete returns a delete query restricted to the rows of the tenant of ctx
+func (t connectionImpl) newDelete(ctx context.Context, model f.Entity) (*bun.DeleteQuery, error) {
+	q := t.db.NewDelete()
+	tenant, err := t.tenantScope(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	if tenant != "" {
+		filter := tenantFilter
+		if !t.db.Dialect().Features().Has(feature.DeleteTableAlias) {
+			// mysql before 8.0.16 and mariadb do not alias the table of deletes
+			filter = tenantColumn + " = ?"
+		}
+		q = q.Where(filter, tenant)
+	}
+	return q, nil
+}
+
+// sharedConnection returns the connection of tenant with the discriminator strategy, a
+// view of the default connection
+func (ds *MultiTenantDataSource) sharedConnection(tenant f.Tenant) (f.Connection, error) {
+	cnx, ok := ds.lookup(_defaultTenantId).(connectionImpl)
+	if !ok {
+		return nil, fmt.Errorf("the discriminator strategy requires a default database")
+	}
+	cnx.Id = tenant.ID
+	cnx.Default = false
+	cnx.tenantSlug = tenant.Slug
+	cnx.shared = true
+	return cnx, nil
+}
+
+// tenantTablesPrefix prefixes the changelog of the tenant migrations applied to the
+// default database with the discriminator strategy
+func (ds *MultiTenantDataSource) tenantTablesPrefix() string {
+	if ds.cfg.Prefix == "" {
+		return "tenant"
+	}
+	return strings.TrimSuffix(ds.cfg.Prefix, "_") + "_tenant"
+}
+
+// migrateTenantTables creates the tables of the tenant migrations in the default database,
+// once for every tenant.
+func (ds *MultiTenantDataSource) migrateTenantTables(ctx context.Context, cnx connectionImpl) error {
+	cnx.Id = _sharedTenantsId
+	cnx.Default = false
+	cnx.discriminator = false
+	if !ds.migrations.empty() {
+		if err := cnx.migrate(ctx, ds.migrations, ds.tenantTablesPrefix()); err != nil {
+			return err
+		}
+	}
+	if ds.cfg.RowLevelSecurity {
+		return cnx.enableRowLevelSecurity(ctx)
+	}
+	return nil
+}
+
+// enableRowLevelSecurity restricts the rows of every table with a tenant_id column to
+// the tenant set by the transactions of the connection, see setRowLevelTenant
+func (t connectionImpl) enableRowLevelSecurity(ctx context.Context) error {
+	if t.dialect != "postgres" {
+		return nil
+	}
+	var tables []string
+	err := t.db.NewRaw(
+		"SELECT table_name FROM information_schema.columns WHERE table_schema = current_schema() AND column_name = ?",
+		tenantColumn,
+	).Scan(ctx, &tables)
+	if err != nil {
+		return fmt.Errorf("failed to list tenant tables: %v", err)
+	}
+	policy := fmt.Sprintf("%s = current_setting('%s', true)", tenantColumn, tenantSetting)
+	for _, table := range tables {
+		for _, query := range []string{
+			"ALTER TABLE ? ENABLE ROW LEVEL SECURITY",
+			// the policies also apply to the owner of the table, usually the application
+			"ALTER TABLE ? FORCE ROW LEVEL SECURITY",
+			"DROP POLICY IF EXISTS tenant_isolation ON ?",
+			"CREATE POLICY tenant_isolation ON ? USING (" + policy + ") WITH CHECK (" + policy + ")",
+		} {
+			if _, err := t.db.ExecContext(ctx, query, bun.Ident(table)); err != nil {
+				return fmt.Errorf("failed to enable row level security on %s: %v", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setRowLevelTenant sets the tenant the row level security policies filter on for the
+// rest of the transaction tx
+func (t connectionImpl) setRowLevelTenant(ctx context.Context, tx bun.Tx) error {
+	if !t.rowLevelSecurity || t.dialect != "postgres" {
+		return nil
+	}
+	tenant := t.currentTenant(ctx)
+	if tenant == "" {
+		// the policies hide every row
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "SELECT set_config(?, ?, true)", tenantSetting, tenant)
+	return err
+}
+
+// rowLevelScoped runs fn in a transaction setting the tenant of ctx when t is not a
+// transaction already, the row level security policies hiding every row outside of
+// them. Reads run in a read-only transaction of a replica when there is one.
+func (t connectionImpl) rowLevelScoped(ctx context.Context, readOnly bool, fn func(t connectionImpl) error) error {
+	if !t.rowLevelSecurity || t.dialect != "postgres" || t.transaction || t.currentTenant(ctx) == "" {
+		return fn(t)
+	}
+	db := t.db
+	if readOnly {
+		db = t.reader(ctx)
+	}
+	return db.RunInTx(ctx, &sql.TxOptions{ReadOnly: readOnly}, func(ctx context.Context, tx bun.Tx) error {
+		if err := t.setRowLevelTenant(ctx, tx); err != nil {
+			return fmt.Errorf("failed to set the tenant of the statement: %v", err)
+		}
+		t.db = tx
+		t.transaction = true
+		return fn(t)
+	})
+}
+
+// rowLevelScopedResult is rowLevelScoped for the operations returning a result
+func rowLevelScopedResult[T any](ctx context.Context, t connectionImpl, readOnly bool, fn func(t connectionImpl) (T, error)) (T, error) {
+	var result T
+	err := t.rowLevelScoped(ctx, readOnly, func(t connectionImpl) (err error) {
+		result, err = fn(t)
+		return err
+	})
+	return result, err
+}
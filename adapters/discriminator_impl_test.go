@@ -0,0 +1,206 @@
+package adapters
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/soffa-projects/foundation-go/h"
+	"github.com/soffa-projects/foundation-go/test"
+	"github.com/uptrace/bun"
+)
+
+type note struct {
+	f.Entity `bun:"table:notes"`
+	ID       string `bun:",pk"`
+	Body     string
+	f.TenantOwned
+}
+
+func newDiscriminatorDS(t *testing.T) *MultiTenantDataSource {
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "shared.db"),
+		Strategy:    f.DiscriminatorStrategy,
+	})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{{ID: "acme", Slug: "acme-corp"}, {ID: "demo"}},
+	})
+	err := ds.Init([]f.Feature{{
+		Name: "notes",
+		FS: fstest.MapFS{
+			"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+			"db/migrations/tenant/001_notes.sql": &fstest.MapFile{Data: []byte(
+				"-- +goose Up\nCREATE TABLE notes (id TEXT PRIMARY KEY, body TEXT, tenant_id TEXT NOT NULL);\n\n-- +goose Down\nDROP TABLE notes;\n",
+			)},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func tenantCtx(tenant string) context.Context {
+	return context.WithValue(context.Background(), f.TenantKey{}, tenant)
+}
+
+func TestDiscriminator_ScopesQueries(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := newDiscriminatorDS(t)
+	acme, demo := tenantCtx("acme"), tenantCtx("demo")
+	cnx := ds.Connection("acme")
+	assert.NotNil(cnx)
+
+	entity := &note{ID: "1", Body: "acme"}
+	assert.Nil(cnx.Insert(acme, entity))
+	assert.Equals(entity.TenantId, "acme")
+	assert.Nil(ds.Connection("demo").InsertBatch(demo, &[]note{{ID: "2", Body: "demo"}, {ID: "3", Body: "demo"}}))
+
+	count, err := cnx.Count(acme, &note{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+	// tenants addressed by slug are scoped by id
+	count, err = cnx.CountBy(tenantCtx("acme-corp"), &note{}, "1 = 1")
+	assert.Nil(err)
+	assert.Equals(count, 1)
+
+	var notes []note
+	_, err = cnx.Query(demo, &notes)
+	assert.Nil(err)
+	assert.Equals(len(notes), 2)
+	page, err := cnx.Paginate(demo, &notes, f.PageRequest{Size: 10})
+	assert.Nil(err)
+	assert.Equals(*page.Total, 2)
+
+	// rows of other tenants are out of reach
+	found := &note{}
+	notFound, err := cnx.FindBy(demo, found, "id = ?", "1")
+	assert.Nil(err)
+	assert.True(notFound)
+	assert.Nil(cnx.Update(demo, &note{ID: "1", Body: "stolen"}))
+	assert.Nil(cnx.DeleteBy(demo, &note{}, "id = ?", "1"))
+	notFound, err = cnx.FindBy(acme, found, "id = ?", "1")
+	assert.Nil(err)
+	assert.False(notFound)
+	assert.Equals(found.Body, "acme")
+	assert.Equals(found.TenantId, "acme")
+
+	assert.Nil(cnx.Upsert(demo, &note{ID: "1", Body: "stolen"}))
+	_, err = cnx.FindBy(acme, found, "id = ?", "1")
+	assert.Nil(err)
+	assert.Equals(found.Body, "acme")
+	assert.Nil(cnx.Upsert(acme, &note{ID: "1", Body: "updated"}))
+	_, err = cnx.FindBy(acme, found, "id = ?", "1")
+	assert.Nil(err)
+	assert.Equals(found.Body, "updated")
+}
+
+func TestDiscriminator_RequiresTenant(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := newDiscriminatorDS(t)
+	ctx := context.Background()
+
+	err := ds.DefaultConnection().Insert(ctx, &note{ID: "1"})
+	assert.True(stderrors.Is(err, f.ErrNoTenant))
+	var customError *errors.CustomError
+	assert.True(stderrors.As(err, &customError))
+	assert.Equals(customError.Code, http.StatusInternalServerError)
+	// every failure gets its own error, changing one leaves ErrNoTenant untouched
+	customError.Message = "changed"
+	assert.Equals(f.ErrNoTenant.Error(), "no tenant in context to scope the query")
+	_, err = ds.Connection("acme").Count(ctx, &note{})
+	assert.True(stderrors.Is(err, f.ErrNoTenant))
+	// entities not owned by tenants are not scoped
+	_, err = ds.DefaultConnection().Count(ctx, &account{})
+	assert.Nil(err)
+}
+
+func TestDiscriminator_SharesTheDefaultConnection(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := newDiscriminatorDS(t)
+
+	assert.Equals(len(ds.Connections()), 1)
+	assert.Equals(ds.Connection("demo").DatabaseUrl(), ds.DefaultConnection().DatabaseUrl())
+
+	// removing a tenant keeps the shared pool open
+	ds.RemoveTenant("demo")
+	assert.Nil(ds.DefaultConnection().Ping())
+
+	tx, err := ds.Connection("acme").Tx(context.Background())
+	assert.Nil(err)
+	assert.Nil(tx.Insert(tenantCtx("acme"), &note{ID: "1"}))
+	assert.Nil(tx.Commit())
+	count, err := ds.Connection("acme").Count(tenantCtx("acme"), &note{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+}
+
+func TestDiscriminator_RowLevelSecurity_Postgres(t *testing.T) {
+	databaseUrl := os.Getenv("TEST_POSTGRES_URL")
+	if databaseUrl == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	assert := test.NewAssertions(t)
+	schema := "rls_" + strings.ToLower(h.RandomString(8))
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl:      h.AppendParamToUrl(databaseUrl, "schema", schema),
+		Strategy:         f.DiscriminatorStrategy,
+		RowLevelSecurity: true,
+	})
+	ds.UseTenantProvider(&mockTenantProvider{tenants: []f.Tenant{{ID: "acme"}, {ID: "demo"}}})
+	assert.Nil(ds.Init([]f.Feature{{
+		Name: "notes",
+		FS: fstest.MapFS{
+			"db/migrations/tenant/001_notes.sql": &fstest.MapFile{Data: []byte(
+				"-- +goose Up\nCREATE TABLE notes (id TEXT PRIMARY KEY, body TEXT, tenant_id TEXT NOT NULL);\n\n-- +goose Down\nDROP TABLE notes;\n",
+			)},
+		},
+	}}))
+	t.Cleanup(func() {
+		db := ds.DefaultConnection().(connectionImpl).db
+		_, _ = db.ExecContext(context.Background(), "DROP SCHEMA ? CASCADE", bun.Ident(schema))
+		_ = ds.Close()
+	})
+	acme, demo := tenantCtx("acme"), tenantCtx("demo")
+	cnx := ds.Connection("acme")
+
+	// outside of a transaction
+	assert.Nil(cnx.Insert(acme, &note{ID: "1", Body: "outside"}))
+	count, err := cnx.Count(acme, &note{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+	assert.Nil(cnx.Update(acme, &note{ID: "1", Body: "updated outside"}, "body"))
+
+	// inside a transaction
+	err = cnx.Transaction(acme, func(tx f.Connection) error {
+		found := &note{}
+		if _, err := tx.FindBy(acme, found, "id = ?", "1"); err != nil {
+			return err
+		}
+		assert.Equals(found.Body, "updated outside")
+		return tx.Insert(acme, &note{ID: "2", Body: "inside"})
+	})
+	assert.Nil(err)
+	var notes []note
+	_, err = cnx.Query(acme, &notes, f.QueryOpts{OrderBy: "id"})
+	assert.Nil(err)
+	assert.Equals(len(notes), 2)
+
+	// the rows of other tenants stay hidden either way
+	count, err = ds.Connection("demo").Count(demo, &note{})
+	assert.Nil(err)
+	assert.Equals(count, 0)
+	err = ds.Connection("demo").Transaction(demo, func(tx f.Connection) error {
+		count, err = tx.Count(demo, &note{})
+		return err
+	})
+	assert.Nil(err)
+	assert.Equals(count, 0)
+}
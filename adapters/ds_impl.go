@@ -2,9 +2,14 @@ package adapters
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	f "github.com/soffa-projects/foundation-go/core"
 	"github.com/soffa-projects/foundation-go/h"
@@ -13,17 +18,36 @@ import (
 
 type MultiTenantDataSource struct {
 	f.DataSource
-	migrationsFS   []fs.FS
-	tenants        map[string]f.Connection
+	migrations migrationSources
+	tenants    map[string]f.Connection
+	// registered are the tenants with a connection, indexed by id
+	registered map[string]f.Tenant
+	// quarantined are the tenants that failed to initialize, indexed by id and slug
+	quarantined    map[string]quarantine
 	tenantProvider f.TenantProvider
 	cfg            f.DataSourceConfig
+	outbox         bool
+	metrics        *instruments
+	mu             sync.RWMutex
+	cancel         context.CancelFunc
+	done           chan struct{}
+}
+
+type quarantine struct {
+	tenant string
+	err    error
 }
 
 type DefaultDataSource struct {
 	f.DataSource
 }
 
-const _defaultTenantId = "default"
+const (
+	_defaultTenantId = "default"
+
+	defaultTenantConcurrency = 8
+	defaultTenantTimeout     = 2 * time.Minute
+)
 
 // ------------------------------------------------------------------------------------------------------------------
 // DATA SOURCE IMPL
@@ -35,14 +59,14 @@ func NewMultiTenantDS(cfg ...f.DataSourceConfig) *MultiTenantDataSource {
 		config = cfg[0]
 	}
 	ds := &MultiTenantDataSource{
-		tenants: make(map[string]f.Connection),
-		cfg:     config,
+		tenants:     make(map[string]f.Connection),
+		registered:  make(map[string]f.Tenant),
+		quarantined: make(map[string]quarantine),
+		cfg:         config,
 	}
-	migrationsFS := []fs.FS{}
 	if ds.cfg.MigrationFS != nil {
-		migrationsFS = append(migrationsFS, ds.cfg.MigrationFS)
+		ds.migrations.fs = append(ds.migrations.fs, ds.cfg.MigrationFS)
 	}
-	ds.migrationsFS = migrationsFS
 	return ds
 }
 
@@ -51,22 +75,40 @@ func (ds *MultiTenantDataSource) UseTenantProvider(tenantProvider f.TenantProvid
 	return ds
 }
 
+// UseMetrics records the duration and failures of the queries of every connection into m
+func (ds *MultiTenantDataSource) UseMetrics(m f.Metrics) *MultiTenantDataSource {
+	ds.metrics = instrumentsOf(m)
+	return ds
+}
+
+// UseOutbox creates the outbox table of every connection, see OutboxRelay
+func (ds *MultiTenantDataSource) UseOutbox() *MultiTenantDataSource {
+	ds.outbox = true
+	return ds
+}
+
 func (ds *MultiTenantDataSource) Init(features []f.Feature) error {
 
-	for _, feature := range features {
-		if feature.FS != nil {
-			ds.migrationsFS = append(ds.migrationsFS, feature.FS)
-		}
-	}
+	ds.migrations = ds.migrationSources(features)
 	if ds.cfg.DatabaseUrl != "" {
-		cnx, err := ds.connect(f.ConnectionConfig{
+		cnx, err := ds.connect(context.Background(), f.ConnectionConfig{
 			Id:          _defaultTenantId,
 			DatabaseUrl: ds.cfg.DatabaseUrl,
+			ReplicaUrls: ds.cfg.ReplicaUrls,
 		})
 		if err != nil {
 			return fmt.Errorf("[001] failed acquire default connection: %v", err)
 		}
+		ds.mu.Lock()
 		ds.tenants[_defaultTenantId] = cnx
+		ds.mu.Unlock()
+		if ds.cfg.Strategy == f.DiscriminatorStrategy {
+			if err := ds.migrateTenantTables(context.Background(), cnx.(connectionImpl)); err != nil {
+				return fmt.Errorf("[001] failed to migrate the tenant tables: %v", err)
+			}
+		}
+	} else if ds.cfg.Strategy == f.DiscriminatorStrategy {
+		return fmt.Errorf("[001] the discriminator strategy requires a DatabaseUrl")
 	}
 	ctx := context.Background()
 	if err := ds.init(ctx); err != nil {
@@ -79,6 +121,50 @@ func (ds *MultiTenantDataSource) Init(features []f.Feature) error {
 	return nil
 }
 
+// Ping checks the default connection and every tenant connection
+func (ds *MultiTenantDataSource) Ping() error {
+	var errs []error
+	pinged := map[f.Connection]bool{}
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	for id, cnx := range ds.tenants {
+		// tenants are indexed by id and slug
+		if pinged[cnx] {
+			continue
+		}
+		pinged[cnx] = true
+		if err := cnx.Ping(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close releases the pools of every tenant connection
+func (ds *MultiTenantDataSource) Close() error {
+	var errs []error
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	// the tenants are indexed by id and by slug
+	closed := map[f.Connection]bool{}
+	for id, cnx := range ds.tenants {
+		if closed[cnx] {
+			continue
+		}
+		closed[cnx] = true
+		if closer, ok := cnx.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close connection %s: %v", id, err))
+			}
+		}
+	}
+	ds.tenants = make(map[string]f.Connection)
+	ds.registered = make(map[string]f.Tenant)
+	return errors.Join(errs...)
+}
+
+// init initializes the tenants of the provider in parallel, a tenant failing to connect
+// or migrate is quarantined rather than failing the whole data source.
 func (ds *MultiTenantDataSource) init(ctx context.Context) error {
 	if ds.tenantProvider == nil {
 		log.Warn("tenant provider is not set")
@@ -88,69 +174,402 @@ func (ds *MultiTenantDataSource) init(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	ds.initTenants(tenantList)
+	return nil
+}
+
+func (ds *MultiTenantDataSource) initTenants(tenantList []f.Tenant) {
+	concurrency := ds.cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultTenantConcurrency
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
 	for _, tenant := range tenantList {
-		err := ds.initTenant(tenant)
-		if err != nil {
-			return err
-		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(tenant f.Tenant) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			_ = ds.initTenant(tenant)
+		}(tenant)
 	}
-	return nil
+	wg.Wait()
 }
 
+// initTenant connects tenant, or reconnects it when its slug or database changed. A
+// failing update keeps the current connection.
 func (ds *MultiTenantDataSource) initTenant(tenant f.Tenant) error {
 
 	tenantId := tenant.ID
 	tenantSlug := tenant.Slug
-	if _, ok := ds.tenants[tenantId]; !ok {
+	ds.mu.RLock()
+	current, updated := ds.registered[tenantId]
+	ds.mu.RUnlock()
+	if updated && ds.sameTenant(current, tenant) {
+		return nil
+	}
 
-		dbUrl := tenant.DatabaseUrl
-		if ds.cfg.Strategy == "schema" && strings.HasPrefix(ds.cfg.DatabaseUrl, "postgres://") {
-			dbUrl = h.AppendParamToUrl(ds.cfg.DatabaseUrl, "schema", tenantId)
-		}
-		cnx, err := ds.connect(f.ConnectionConfig{
-			Id:          tenantId,
-			DatabaseUrl: dbUrl,
-		})
-		if err != nil {
+	timeout := ds.cfg.TenantTimeout
+	if timeout <= 0 {
+		timeout = defaultTenantTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cnx, err := ds.connectTenant(ctx, tenant)
+	if err != nil {
+		if updated {
+			log.Error("failed to update tenant %s (%s), keeping its current connection: %v", tenantId, tenantSlug, err)
 			return err
 		}
-		ds.tenants[tenantId] = cnx
-		ds.tenants[tenantSlug] = cnx
+		log.Error("tenant %s (%s) quarantined: %v", tenantId, tenantSlug, err)
+		ds.mu.Lock()
+		ds.quarantined[tenantId] = quarantine{tenant: tenantId, err: err}
+		ds.quarantined[tenantSlug] = quarantine{tenant: tenantId, err: err}
+		ds.mu.Unlock()
+		return err
+	}
+
+	ds.mu.Lock()
+	if registered, ok := ds.registered[tenantId]; ok && ds.sameTenant(registered, tenant) {
+		// initialized concurrently
+		ds.mu.Unlock()
+		closeConnection(cnx)
+		return nil
+	}
+	previous := ds.unregister(tenantId)
+	delete(ds.quarantined, tenantId)
+	delete(ds.quarantined, tenantSlug)
+	ds.tenants[tenantId] = cnx
+	ds.tenants[tenantSlug] = cnx
+	ds.registered[tenantId] = tenant
+	ds.mu.Unlock()
+
+	if previous != nil {
+		// the pool waits for the queries in progress before closing
+		closeConnection(previous)
+		log.Info("tenant %s (%s) connection updated", tenantId, tenantSlug)
+		ds.fireTenantEvent(f.TenantUpdatedEvent, tenant)
+	} else {
 		log.Info("tenant %s (%s) connection initialized", tenantId, tenantSlug)
+		ds.fireTenantEvent(f.TenantAddedEvent, tenant)
+	}
+	return nil
+}
+
+// sameTenant reports whether the connection of a tenant defined as current can be kept
+// for its definition as tenant
+func (ds *MultiTenantDataSource) sameTenant(current f.Tenant, tenant f.Tenant) bool {
+	return current.Slug == tenant.Slug &&
+		ds.tenantUrl(current) == ds.tenantUrl(tenant) &&
+		slices.Equal(ds.tenantReplicas(current), ds.tenantReplicas(tenant))
+}
+
+// RemoveTenant closes the connection of a tenant, by id or slug, and forgets it
+func (ds *MultiTenantDataSource) RemoveTenant(id string) {
+	ds.mu.Lock()
+	tenant, ok := ds.registered[id]
+	if !ok {
+		for _, registered := range ds.registered {
+			if registered.Slug == id {
+				tenant, ok = registered, true
+				break
+			}
+		}
+	}
+	if q, quarantined := ds.quarantined[id]; quarantined {
+		for key, value := range ds.quarantined {
+			if value.tenant == q.tenant {
+				delete(ds.quarantined, key)
+			}
+		}
+	}
+	var cnx f.Connection
+	if ok {
+		cnx = ds.unregister(tenant.ID)
+	}
+	ds.mu.Unlock()
+
+	if cnx == nil {
+		return
+	}
+	closeConnection(cnx)
+	log.Info("tenant %s (%s) removed", tenant.ID, tenant.Slug)
+	ds.fireTenantEvent(f.TenantRemovedEvent, tenant)
+}
+
+// Reload synchronizes the connections with the tenants of the provider: new tenants are
+// connected, changed ones reconnected and the ones no longer listed removed.
+func (ds *MultiTenantDataSource) Reload(ctx context.Context) error {
+	if ds.tenantProvider == nil {
+		return nil
+	}
+	tenantList, err := ds.tenantProvider.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load tenants: %v", err)
+	}
+	ds.initTenants(tenantList)
+
+	listed := make(map[string]bool, len(tenantList))
+	for _, tenant := range tenantList {
+		listed[tenant.ID] = true
+	}
+	var removed []string
+	ds.mu.RLock()
+	for id := range ds.registered {
+		if !listed[id] {
+			removed = append(removed, id)
+		}
+	}
+	for _, q := range ds.quarantined {
+		if !listed[q.tenant] {
+			removed = append(removed, q.tenant)
+		}
 	}
+	ds.mu.RUnlock()
+	for _, id := range removed {
+		ds.RemoveTenant(id)
+	}
+	return nil
+}
 
+// Start reloads the tenants every DataSourceConfig.ReloadInterval and refreshes the health
+// of the replicas until Stop is called
+func (ds *MultiTenantDataSource) Start(ctx context.Context) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.cancel != nil {
+		return nil
+	}
+	ctx, ds.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	ds.done = make(chan struct{})
+	go ds.run(ctx, ds.done)
 	return nil
 }
 
+func (ds *MultiTenantDataSource) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	var reload <-chan time.Time
+	if ds.cfg.ReloadInterval > 0 && ds.tenantProvider != nil {
+		ticker := time.NewTicker(ds.cfg.ReloadInterval)
+		defer ticker.Stop()
+		reload = ticker.C
+		log.Info("tenants reloaded every %s", ds.cfg.ReloadInterval)
+	}
+	replicas := time.NewTicker(replicaCheckInterval)
+	defer replicas.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			if err := ds.Reload(ctx); err != nil && ctx.Err() == nil {
+				log.Error("failed to reload tenants: %v", err)
+			}
+		case <-replicas.C:
+			ds.checkReplicas(ctx)
+		}
+	}
+}
+
+// Stop waits for the reload in progress to complete and closes the connections
+func (ds *MultiTenantDataSource) Stop(ctx context.Context) error {
+	ds.mu.Lock()
+	cancel, done := ds.cancel, ds.done
+	ds.cancel = nil
+	ds.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return fmt.Errorf("tenant reload shutdown: %w", ctx.Err())
+		}
+	}
+	return ds.Close()
+}
+
+// unregister removes the entries of a registered tenant and returns its connection,
+// ds.mu must be held.
+func (ds *MultiTenantDataSource) unregister(tenantId string) f.Connection {
+	tenant, ok := ds.registered[tenantId]
+	if !ok {
+		return nil
+	}
+	cnx := ds.tenants[tenantId]
+	delete(ds.registered, tenantId)
+	delete(ds.tenants, tenantId)
+	delete(ds.tenants, tenant.Slug)
+	return cnx
+}
+
+func (ds *MultiTenantDataSource) fireTenantEvent(evt string, tenant f.Tenant) {
+	defer func() {
+		if value := recover(); value != nil {
+			log.Error("%s listener failed for tenant %s: %v", evt, tenant.ID, value)
+		}
+	}()
+	f.FireEvent(context.Background(), evt, map[string]any{"data": tenant})
+}
+
+func closeConnection(cnx f.Connection) {
+	if closer, ok := cnx.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Warn("failed to close connection: %v", err)
+		}
+	}
+}
+
+// Unavailable returns the error that quarantined tenantId, nil when it is available
+func (ds *MultiTenantDataSource) Unavailable(tenantId string) error {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	if q, ok := ds.quarantined[tenantId]; ok {
+		return q.err
+	}
+	return nil
+}
+
+// CheckTenants reports the quarantined tenants as a degraded health component
+func (ds *MultiTenantDataSource) CheckTenants(ctx context.Context) error {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	var failures []string
+	for id, q := range ds.quarantined {
+		// skip the entries indexed by slug
+		if id == q.tenant {
+			failures = append(failures, fmt.Sprintf("%s: %v", id, q.err))
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Strings(failures)
+	return f.Degraded(fmt.Errorf("%d tenant(s) unavailable: %s", len(failures), strings.Join(failures, "; ")))
+}
+
+// tenantUrl returns the database url of tenant, its schema in the default database with
+// the schema strategy (its database on a mysql server) and the default database with the
+// discriminator strategy
+func (ds *MultiTenantDataSource) tenantUrl(tenant f.Tenant) string {
+	switch {
+	case ds.cfg.Strategy == f.SchemaStrategy && hasSchemas(ds.cfg.DatabaseUrl):
+		return h.AppendParamToUrl(ds.cfg.DatabaseUrl, "schema", tenant.ID)
+	case ds.cfg.Strategy == f.DiscriminatorStrategy:
+		return ds.cfg.DatabaseUrl
+	}
+	return tenant.DatabaseUrl
+}
+
+// migrationSources returns the migrations of the data source followed by the ones of features
+func (ds *MultiTenantDataSource) migrationSources(features []f.Feature) migrationSources {
+	sources := migrationSources{
+		fs:    slices.Clone(ds.migrations.fs),
+		funcs: slices.Clone(ds.migrations.funcs),
+	}
+	for _, feature := range features {
+		if feature.FS != nil {
+			sources.fs = append(sources.fs, feature.FS)
+		}
+		sources.funcs = append(sources.funcs, feature.Migrations...)
+	}
+	return sources
+}
+
+// Connections returns the default and tenant connections indexed by tenant id
+func (ds *MultiTenantDataSource) Connections() map[string]f.Connection {
+	connections := make(map[string]f.Connection)
+	seen := map[f.Connection]bool{}
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	for id, cnx := range ds.tenants {
+		if seen[cnx] {
+			continue
+		}
+		seen[cnx] = true
+		impl, ok := cnx.(connectionImpl)
+		if ok && impl.shared {
+			// the tenants of the discriminator strategy use the default connection
+			continue
+		}
+		if ok && impl.Id != "" {
+			id = impl.Id
+		}
+		connections[id] = cnx
+	}
+	return connections
+}
+
 func (ds *MultiTenantDataSource) DefaultConnection() f.Connection {
-	return ds.tenants[_defaultTenantId]
+	return ds.lookup(_defaultTenantId)
 }
 
+// Connection returns the connection of a tenant by id or slug, nil when the tenant is
+// unknown or quarantined.
 func (ds *MultiTenantDataSource) Connection(id string) f.Connection {
-	if cnx, ok := ds.tenants[id]; ok {
+	if cnx := ds.lookup(id); cnx != nil {
 		return cnx
 	}
+	if ds.Unavailable(id) != nil {
+		return nil
+	}
 	log.Debug("tenant connexion %s not found, initializing...", id)
 	if err := ds.init(context.Background()); err != nil {
 		panic(fmt.Sprintf("tenant connexion %s not found", id))
 	}
-	if cnx, ok := ds.tenants[id]; ok {
-		return cnx
-	}
-	return nil
+	return ds.lookup(id)
 	//panic(fmt.Sprintf("tenant connexion %s not found", id))
 }
 
-func (ds *MultiTenantDataSource) connect(config f.ConnectionConfig) (f.Connection, error) {
+func (ds *MultiTenantDataSource) lookup(id string) f.Connection {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.tenants[id]
+}
+
+// connectTenant opens the connection of tenant, a view of the default connection with the
+// discriminator strategy
+func (ds *MultiTenantDataSource) connectTenant(ctx context.Context, tenant f.Tenant) (f.Connection, error) {
+	if ds.cfg.Strategy == f.DiscriminatorStrategy {
+		return ds.sharedConnection(tenant)
+	}
+	return ds.connect(ctx, f.ConnectionConfig{
+		Id:          tenant.ID,
+		DatabaseUrl: ds.tenantUrl(tenant),
+		ReplicaUrls: ds.tenantReplicas(tenant),
+	})
+}
+
+func (ds *MultiTenantDataSource) connect(ctx context.Context, config f.ConnectionConfig) (f.Connection, error) {
 	cnx := connectionImpl{
-		Id:      config.Id,
-		Url:     config.DatabaseUrl,
-		Default: config.Id == _defaultTenantId,
+		Id:               config.Id,
+		Url:              config.DatabaseUrl,
+		Default:          config.Id == _defaultTenantId,
+		discriminator:    ds.cfg.Strategy == f.DiscriminatorStrategy,
+		rowLevelSecurity: ds.cfg.RowLevelSecurity,
+		metrics:          ds.metrics,
 	}
-	err := cnx.configure(ds.migrationsFS, ds.cfg.Prefix)
+	err := cnx.configure(ctx, ds.migrations, ds.cfg.Prefix)
 	if err != nil {
+		if cnx.db != nil {
+			_ = cnx.Close()
+		}
 		return nil, err
 	}
+	if ds.outbox {
+		if err := createOutboxTable(ctx, cnx); err != nil {
+			_ = cnx.Close()
+			return nil, err
+		}
+	}
+	if len(config.ReplicaUrls) > 0 {
+		if cnx.replicas, err = openReplicas(ctx, cnx, config.ReplicaUrls, ds.cfg.MaxReplicaLag); err != nil {
+			_ = cnx.Close()
+			return nil, fmt.Errorf("failed to open the replicas of %s: %v", config.Id, err)
+		}
+	}
 	cnx.initialized = true
 	return cnx, nil
 }
@@ -2,7 +2,12 @@ package adapters
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	f "github.com/soffa-projects/foundation-go/core"
 	"github.com/soffa-projects/foundation-go/test"
@@ -423,7 +428,7 @@ func TestMultiTenantDS_TenantWithoutDatabaseUrl(t *testing.T) {
 func TestMultiTenantDS_Connect_InvalidUrl(t *testing.T) {
 	ds := NewMultiTenantDS()
 
-	_, err := ds.connect(f.ConnectionConfig{
+	_, err := ds.connect(context.Background(), f.ConnectionConfig{
 		Id:          "test",
 		DatabaseUrl: "invalid://url",
 	})
@@ -433,6 +438,96 @@ func TestMultiTenantDS_Connect_InvalidUrl(t *testing.T) {
 	}
 }
 
+// ------------------------------------------------------------------------------------------------------------------
+// Quarantine Tests
+// ------------------------------------------------------------------------------------------------------------------
+
+func TestMultiTenantDS_Init_QuarantinesFailingTenants(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: test.TestDatabaseURL()})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{
+			{ID: "broken", Slug: "broken-org", DatabaseUrl: "invalid://url"},
+			{ID: "acme", Slug: "acme-corp", DatabaseUrl: test.TestDatabaseURL()},
+		},
+	})
+
+	err := ds.Init([]f.Feature{})
+	assert.Nil(err)
+	assert.NotNil(ds.Connection("acme"))
+	assert.True(ds.Connection("broken") == nil)
+	assert.NotNil(ds.Unavailable("broken"))
+	assert.NotNil(ds.Unavailable("broken-org"))
+	assert.Nil(ds.Unavailable("acme"))
+
+	health := f.NewHealthChecks(time.Second)
+	health.Add("tenants", ds.CheckTenants)
+	res := health.Run(context.Background(), "test")
+	assert.Equals(res.Status, "UP")
+	assert.Equals(res.Components["tenants"].Status, "DEGRADED")
+	assert.True(strings.HasPrefix(res.Components["tenants"].Message, "1 tenant(s) unavailable: broken:"))
+}
+
+func TestMultiTenantDS_Init_TenantTimeout(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{TenantTimeout: 50 * time.Millisecond})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{{ID: "slow", DatabaseUrl: test.TestDatabaseURL()}},
+	})
+	err := ds.Init([]f.Feature{{
+		Name: "slow",
+		Migrations: []f.GoMigration{{
+			Version: 1,
+			Name:    "wait",
+			Tenant:  true,
+			Up: func(ctx context.Context, cnx f.Connection) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}},
+	}})
+	assert.Nil(err)
+	assert.NotNil(ds.Unavailable("slow"))
+	assert.True(strings.Contains(ds.Unavailable("slow").Error(), "deadline exceeded"))
+}
+
+func TestMultiTenantDS_Init_BoundedConcurrency(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	var running, peak atomic.Int32
+	var tenants []f.Tenant
+	for i := range 6 {
+		tenants = append(tenants, f.Tenant{ID: fmt.Sprintf("t%d", i), DatabaseUrl: test.TestDatabaseURL()})
+	}
+	ds := NewMultiTenantDS(f.DataSourceConfig{Concurrency: 2})
+	ds.UseTenantProvider(&mockTenantProvider{tenants: tenants})
+	err := ds.Init([]f.Feature{{
+		Name: "track",
+		Migrations: []f.GoMigration{{
+			Version: 1,
+			Name:    "track",
+			Tenant:  true,
+			Up: func(ctx context.Context, cnx f.Connection) error {
+				current := running.Add(1)
+				defer running.Add(-1)
+				for {
+					value := peak.Load()
+					if current <= value || peak.CompareAndSwap(value, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			},
+		}},
+	}})
+	assert.Nil(err)
+	assert.Equals(len(ds.Connections()), 6)
+	assert.True(peak.Load() <= 2)
+}
+
 // ------------------------------------------------------------------------------------------------------------------
 // Integration Tests
 // ------------------------------------------------------------------------------------------------------------------
@@ -479,6 +574,144 @@ func TestMultiTenantDS_EndToEnd(t *testing.T) {
 	assert.Equals(acmeBySlug.DatabaseUrl(), acmeCnx.DatabaseUrl())
 }
 
+// ------------------------------------------------------------------------------------------------------------------
+// Registry Tests
+// ------------------------------------------------------------------------------------------------------------------
+
+func sqliteFile(t *testing.T, name string) string {
+	return "sqlite://" + filepath.Join(t.TempDir(), name+".db")
+}
+
+func TestMultiTenantDS_UpdateTenant(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	provider := &mockTenantProvider{
+		tenants: []f.Tenant{{ID: "upd", Slug: "upd-corp", DatabaseUrl: sqliteFile(t, "v1")}},
+	}
+	ds := NewMultiTenantDS(f.DataSourceConfig{})
+	ds.UseTenantProvider(provider)
+	assert.Nil(ds.Init([]f.Feature{}))
+	previous := ds.Connection("upd")
+	assert.NotNil(previous)
+
+	// unchanged tenants keep their connection
+	assert.Nil(ds.Reload(context.Background()))
+	assert.Equals(ds.Connection("upd").DatabaseUrl(), previous.DatabaseUrl())
+
+	provider.tenants = []f.Tenant{{ID: "upd", Slug: "upd-inc", DatabaseUrl: sqliteFile(t, "v2")}}
+	assert.Nil(ds.Reload(context.Background()))
+	assert.True(strings.HasSuffix(ds.Connection("upd").DatabaseUrl(), "v2.db"))
+	assert.NotNil(ds.Connection("upd-inc"))
+	assert.True(ds.Connection("upd-corp") == nil)
+	assert.NotNil(previous.Ping())
+
+	// a failing update keeps the current connection
+	provider.tenants = []f.Tenant{{ID: "upd", Slug: "upd-inc", DatabaseUrl: "invalid://url"}}
+	assert.Nil(ds.Reload(context.Background()))
+	assert.Nil(ds.Connection("upd").Ping())
+	assert.Nil(ds.Unavailable("upd"))
+}
+
+func TestMultiTenantDS_RemoveTenant(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	var removed atomic.Value
+	f.OnEvent(context.Background(), f.TenantRemovedEvent, func(data map[string]any) error {
+		if tenant := data["data"].(f.Tenant); tenant.ID == "rm" {
+			removed.Store(tenant.Slug)
+		}
+		return nil
+	})
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: test.TestDatabaseURL()})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{{ID: "rm", Slug: "rm-corp", DatabaseUrl: test.TestDatabaseURL()}},
+	})
+	assert.Nil(ds.Init([]f.Feature{}))
+	cnx := ds.Connection("rm")
+	assert.NotNil(cnx)
+
+	ds.RemoveTenant("rm-corp")
+	assert.Equals(removed.Load(), "rm-corp")
+	assert.NotNil(cnx.Ping())
+	_, ok := ds.Connections()["rm"]
+	assert.False(ok)
+	// the default connection is not a tenant
+	ds.RemoveTenant(_defaultTenantId)
+	assert.Nil(ds.DefaultConnection().Ping())
+}
+
+func TestMultiTenantDS_Reload(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	var added atomic.Int32
+	f.OnEvent(context.Background(), f.TenantAddedEvent, func(data map[string]any) error {
+		if strings.HasPrefix(data["data"].(f.Tenant).ID, "reload-") {
+			added.Add(1)
+		}
+		return nil
+	})
+	provider := &mockTenantProvider{
+		tenants: []f.Tenant{
+			{ID: "reload-1", DatabaseUrl: test.TestDatabaseURL()},
+			{ID: "reload-broken", DatabaseUrl: "invalid://url"},
+		},
+	}
+	ds := NewMultiTenantDS(f.DataSourceConfig{})
+	ds.UseTenantProvider(provider)
+	assert.Nil(ds.Init([]f.Feature{}))
+	assert.Equals(added.Load(), int32(1))
+	assert.NotNil(ds.Unavailable("reload-broken"))
+
+	provider.tenants = []f.Tenant{{ID: "reload-2", DatabaseUrl: test.TestDatabaseURL()}}
+	assert.Nil(ds.Reload(context.Background()))
+	assert.Equals(added.Load(), int32(2))
+	assert.NotNil(ds.Connection("reload-2"))
+	_, ok := ds.Connections()["reload-1"]
+	assert.False(ok)
+	// removed tenants are no longer quarantined
+	assert.Nil(ds.Unavailable("reload-broken"))
+}
+
+func TestMultiTenantDS_StartReloadsPeriodically(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{ReloadInterval: 10 * time.Millisecond})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{{ID: "periodic", DatabaseUrl: test.TestDatabaseURL()}},
+	})
+	assert.Nil(ds.Start(context.Background()))
+	deadline := time.Now().Add(2 * time.Second)
+	for ds.lookup("periodic") == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(ds.lookup("periodic") != nil)
+	assert.Nil(ds.Stop(context.Background()))
+	assert.Equals(len(ds.Connections()), 0)
+}
+
+type closeCounter struct {
+	f.Connection
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestMultiTenantDS_Close_ClosesEachConnectionOnce(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{})
+	cnx := &closeCounter{}
+	// the tenants are indexed by id and by slug
+	ds.tenants["acme"] = cnx
+	ds.tenants["acme-corp"] = cnx
+	assert.Nil(ds.Close())
+	assert.Equals(cnx.closed, 1)
+	assert.Equals(len(ds.Connections()), 0)
+}
+
 // NOTE: These tests focus on in-memory SQLite databases for simplicity.
 // PostgreSQL-specific features (schema strategy) are not tested here.
 //
@@ -0,0 +1,148 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/uptrace/bun"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// ENTITY BEHAVIORS
+// ------------------------------------------------------------------------------------------------------------------
+
+const (
+	updatedAtColumn = "updated_at"
+	updatedByColumn = "updated_by"
+	versionColumn   = "version"
+)
+
+// beforeInsert fills the timestamps, audit and version columns of entity, a single
+// entity or a pointer to a slice of them.
+func beforeInsert(ctx context.Context, entity f.Entity) {
+	now, user := time.Now(), f.ActingUser(ctx)
+	forEachEntity(entity, func(e any) {
+		if t, ok := e.(f.TimestampedEntity); ok {
+			t.SetTimestamps(now, true)
+		}
+		if a, ok := e.(f.AuditedEntity); ok && user != "" {
+			a.SetAuditUser(user, true)
+		}
+		if v, ok := e.(f.VersionedEntity); ok && v.GetVersion() == 0 {
+			v.SetVersion(1)
+		}
+	})
+}
+
+// beforeUpdate refreshes the updated_at and updated_by columns of entity and adds them
+// to columns when the update is restricted to some columns.
+func beforeUpdate(ctx context.Context, entity f.Entity, columns []string) []string {
+	restricted := len(columns) > 0
+	if t, ok := entity.(f.TimestampedEntity); ok {
+		t.SetTimestamps(time.Now(), false)
+		if restricted && !slices.Contains(columns, updatedAtColumn) {
+			columns = append(columns, updatedAtColumn)
+		}
+	}
+	if a, ok := entity.(f.AuditedEntity); ok {
+		if user := f.ActingUser(ctx); user != "" {
+			a.SetAuditUser(user, false)
+			if restricted && !slices.Contains(columns, updatedByColumn) {
+				columns = append(columns, updatedByColumn)
+			}
+		}
+	}
+	return columns
+}
+
+// updateEntity updates entity by primary key, and tenant when set. Versioned entities are
+// only updated when their version is the stored one and get it incremented.
+func updateEntity(ctx context.Context, db bun.IDB, entity f.Entity, tenant string, columns ...string) error {
+	_, err := updateRows(ctx, db, entity, tenant, columns, "")
+	return err
+}
+
+// updateRows updates the rows matching where, the primary key of entity when empty, with
+// the optimistic locking of updateEntity, and returns the number of rows updated.
+func updateRows(ctx context.Context, db bun.IDB, entity f.Entity, tenant string, columns []string, where string, args ...any) (int64, error) {
+	columns = beforeUpdate(ctx, entity, columns)
+	q := db.NewUpdate().Model(entity)
+	if where == "" {
+		q = q.WherePK()
+	} else {
+		q = q.Where(where, args...)
+	}
+	if tenant != "" {
+		q = q.Where(tenantFilter, tenant)
+	}
+	versioned, ok := entity.(f.VersionedEntity)
+	if !ok {
+		res, err := q.Column(columns...).Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	current := versioned.GetVersion()
+	versioned.SetVersion(current + 1)
+	if len(columns) > 0 && !slices.Contains(columns, versionColumn) {
+		columns = append(columns, versionColumn)
+	}
+	var updated int64
+	res, err := q.
+		Column(columns...).
+		Where("? = ?", bun.Ident(versionColumn), current).
+		Exec(ctx)
+	if err == nil {
+		if updated, err = res.RowsAffected(); err == nil && updated == 0 {
+			err = staleVersion(current)
+		}
+	}
+	if err != nil {
+		versioned.SetVersion(current)
+		return 0, err
+	}
+	return updated, nil
+}
+
+func staleVersion(version int64) error {
+	return errors.Conflict(fmt.Sprintf("version %d is stale, the entity was modified or deleted concurrently", version))
+}
+
+// upsertColumns returns the columns overwritten when an upsert conflicts, all but the
+// primary key and the creation audit columns. The version of Versioned entities is
+// among them, the upserts increment it instead.
+func upsertColumns(db bun.IDB, entity f.Entity) []string {
+	table := db.Dialect().Tables().Get(reflect.TypeOf(entity))
+	var columns []string
+	for _, field := range table.DataFields {
+		if field.Name != "created_at" && field.Name != "created_by" {
+			columns = append(columns, field.Name)
+		}
+	}
+	return columns
+}
+
+func forEachEntity(entity f.Entity, fn func(e any)) {
+	value := reflect.ValueOf(entity)
+	if value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Slice {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice {
+		fn(entity)
+		return
+	}
+	for i := 0; i < value.Len(); i++ {
+		item := value.Index(i)
+		if item.Kind() != reflect.Pointer && item.CanAddr() {
+			item = item.Addr()
+		}
+		fn(item.Interface())
+	}
+}
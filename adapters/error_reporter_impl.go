@@ -0,0 +1,155 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/h"
+	"github.com/soffa-projects/foundation-go/log"
+)
+
+func NewErrorReporter(provider string, env string) (f.ErrorReporter, error) {
+	if provider == "memory" {
+		return NewInMemoryErrorReporter(), nil
+	}
+	cfg, err := h.ParseUrl(provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse error reporter: %v", err)
+	}
+	switch cfg.Scheme {
+	case "https", "http":
+		log.Info("using sentry error reporter...")
+		return NewSentryErrorReporter(provider, env), nil
+	case "sentry+https", "sentry+http":
+		log.Info("using sentry error reporter...")
+		return NewSentryErrorReporter(strings.TrimPrefix(provider, "sentry+"), env), nil
+	case "log":
+		log.Info("using log error reporter...")
+		return NewLogErrorReporter(), nil
+	case "memory", "faker":
+		log.Info("using in-memory error reporter...")
+		return NewInMemoryErrorReporter(), nil
+	default:
+		return nil, fmt.Errorf("unsupported error reporter: %s", cfg.Scheme)
+	}
+}
+
+func MustNewErrorReporter(provider string, env string) f.ErrorReporter {
+	reporter, err := NewErrorReporter(provider, env)
+	if err != nil {
+		panic(err)
+	}
+	return reporter
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// LOG ERROR REPORTER IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+type LogErrorReporter struct {
+	f.ErrorReporter
+}
+
+func NewLogErrorReporter() f.ErrorReporter {
+	return &LogErrorReporter{}
+}
+
+func (r *LogErrorReporter) CaptureError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	log.Error("[error-reporter] %v %s", err, formatTags(f.ErrorScopeFromContext(ctx).Tags()))
+}
+
+func (r *LogErrorReporter) CapturePanic(ctx context.Context, value any) {
+	log.Error("[error-reporter] panic: %v %s", value, formatTags(f.ErrorScopeFromContext(ctx).Tags()))
+}
+
+func (r *LogErrorReporter) AddBreadcrumb(ctx context.Context, breadcrumb f.Breadcrumb) {
+	log.Debug("[error-reporter] breadcrumb %s: %s", breadcrumb.Category, breadcrumb.Message)
+}
+
+func (r *LogErrorReporter) Flush(timeout time.Duration) bool {
+	return true
+}
+
+func formatTags(tags map[string]string) string {
+	parts := make([]string, 0, len(tags))
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, tags[key]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// IN-MEMORY ERROR REPORTER IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+// ReportedError is an event recorded by InMemoryErrorReporter
+type ReportedError struct {
+	Err   error
+	Panic any
+	Scope f.ErrorScope
+}
+
+type InMemoryErrorReporter struct {
+	f.ErrorReporter
+	mu          sync.Mutex
+	errors      []ReportedError
+	breadcrumbs []f.Breadcrumb
+}
+
+func NewInMemoryErrorReporter() *InMemoryErrorReporter {
+	return &InMemoryErrorReporter{}
+}
+
+func (r *InMemoryErrorReporter) CaptureError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, ReportedError{Err: err, Scope: f.ErrorScopeFromContext(ctx)})
+}
+
+func (r *InMemoryErrorReporter) CapturePanic(ctx context.Context, value any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, ReportedError{Panic: value, Scope: f.ErrorScopeFromContext(ctx)})
+}
+
+func (r *InMemoryErrorReporter) AddBreadcrumb(ctx context.Context, breadcrumb f.Breadcrumb) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breadcrumbs = append(r.breadcrumbs, breadcrumb)
+}
+
+func (r *InMemoryErrorReporter) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// Errors returns a copy of the reported errors and panics
+func (r *InMemoryErrorReporter) Errors() []ReportedError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ReportedError{}, r.errors...)
+}
+
+func (r *InMemoryErrorReporter) Breadcrumbs() []f.Breadcrumb {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]f.Breadcrumb{}, r.breadcrumbs...)
+}
+
+func (r *InMemoryErrorReporter) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = nil
+	r.breadcrumbs = nil
+}
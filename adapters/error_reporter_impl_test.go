@@ -0,0 +1,85 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+func TestNewErrorReporter_Schemes(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	reporter, err := NewErrorReporter("memory://", "test")
+	assert.Nil(err)
+	_, ok := reporter.(*InMemoryErrorReporter)
+	assert.True(ok)
+
+	reporter, err = NewErrorReporter("log://", "test")
+	assert.Nil(err)
+	_, ok = reporter.(*LogErrorReporter)
+	assert.True(ok)
+
+	reporter, err = NewErrorReporter("sentry+https://key@sentry.example.com/1", "test")
+	assert.Nil(err)
+	_, ok = reporter.(*SentryErrorReporter)
+	assert.True(ok)
+
+	_, err = NewErrorReporter("ftp://localhost", "test")
+	assert.NotNil(err)
+}
+
+func TestInMemoryErrorReporter_CapturesScope(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	reporter := NewInMemoryErrorReporter()
+	ctx := context.WithValue(context.Background(), f.TenantKey{}, "acme")
+	ctx = context.WithValue(ctx, f.AuthenticationKey{}, &f.Authentication{UserId: "u1"})
+	ctx = context.WithValue(ctx, f.RequestIdKey{}, "req-1")
+	ctx = context.WithValue(ctx, f.RouteKey{}, "GET /items")
+
+	reporter.CaptureError(ctx, errors.Technical("boom"))
+	reporter.CapturePanic(ctx, "crash")
+	reporter.AddBreadcrumb(ctx, f.Breadcrumb{Category: "db", Message: "query"})
+
+	reported := reporter.Errors()
+	assert.Equals(len(reported), 2)
+	assert.Equals(reported[0].Err.Error(), "boom")
+	assert.Equals(reported[0].Scope, f.ErrorScope{TenantId: "acme", UserId: "u1", RequestId: "req-1", Route: "GET /items"})
+	assert.Equals(reported[1].Panic, "crash")
+	assert.Equals(len(reporter.Breadcrumbs()), 1)
+
+	reporter.Reset()
+	assert.Equals(len(reporter.Errors()), 0)
+}
+
+func TestRunJob_ReportsFailuresAndPanics(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	reporter := NewInMemoryErrorReporter()
+	job := f.Job{ID: "job-1", Type: "email:send", Payload: []byte(`{"to":"a@b.c"}`)}
+
+	err := runJob(context.Background(), noopInstruments, reporter, job, func(ctx context.Context, job f.Job) error {
+		var payload struct {
+			To string `json:"to"`
+		}
+		assert.Nil(job.Bind(&payload))
+		assert.Equals(payload.To, "a@b.c")
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equals(len(reporter.Errors()), 0)
+
+	err = runJob(context.Background(), noopInstruments, reporter, job, func(ctx context.Context, job f.Job) error {
+		panic("boom")
+	})
+	assert.NotNil(err)
+
+	reported := reporter.Errors()
+	assert.Equals(len(reported), 1)
+	assert.Equals(reported[0].Panic, "boom")
+	assert.Equals(reported[0].Scope.Route, "job:email:send")
+	assert.Equals(reported[0].Scope.RequestId, "job-1")
+}
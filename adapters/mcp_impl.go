@@ -3,11 +3,13 @@ package adapters
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/invopop/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
 )
 
 type mcpServerImpl struct {
@@ -75,10 +77,11 @@ func (s *mcpServerImpl) Add(op f.MCP) {
 	mcp.AddTool(s.internal, tool,
 		func(ctx context.Context, req *mcp.CallToolRequest, input map[string]any) (*mcp.CallToolResult, any, error) {
 			// Create MCP context for the handler
+			ctx = context.WithValue(ctx, f.RouteKey{}, "mcp:"+op.Name)
 			c := &mcpOperationContextImpl{ctx: ctx}
 
 			// Call the user's handler
-			res, err := op.Handle(c)
+			res, err := s.handle(ctx, op, c)
 			if err != nil {
 				// Return error as tool result
 				return &mcp.CallToolResult{
@@ -118,11 +121,33 @@ func (s *mcpServerImpl) Add(op f.MCP) {
 	s.tools++
 }
 
+// handle runs the operation, turning panics into errors and reporting server failures.
+func (s *mcpServerImpl) handle(ctx context.Context, op f.MCP, c *mcpOperationContextImpl) (res any, err error) {
+	reporter := s.cfg.ErrorReporter
+	defer func() {
+		if value := recover(); value != nil {
+			if reporter != nil {
+				reporter.CapturePanic(ctx, value)
+			}
+			err = fmt.Errorf("%v", value)
+		}
+	}()
+	res, err = op.Handle(c)
+	if err != nil && reporter != nil && errors.GetStatusCode(err) >= http.StatusInternalServerError {
+		reporter.CaptureError(ctx, err)
+	}
+	return res, err
+}
+
 type mcpOperationContextImpl struct {
 	f.Context
 	ctx context.Context
 }
 
+func (r *mcpOperationContextImpl) Container() *f.Container {
+	return f.ContainerFrom(r.ctx)
+}
+
 func (r *mcpOperationContextImpl) Structured(data any) any {
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -0,0 +1,201 @@
+package adapters
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/log"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// PROMETHEUS METRICS IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+type PrometheusMetrics struct {
+	f.Metrics
+	registry   *prometheus.Registry
+	mu         sync.Mutex
+	collectors map[string]any
+}
+
+// NewPrometheusMetrics creates a registry exposing the Go runtime and process
+// metrics along with the application ones.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return &PrometheusMetrics{
+		registry:   registry,
+		collectors: make(map[string]any),
+	}
+}
+
+func (m *PrometheusMetrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+func (m *PrometheusMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *PrometheusMetrics) Counter(name string, help string, labels ...string) f.Counter {
+	return register(m, name, func() prometheusCounter {
+		return prometheusCounter{prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)}
+	}, func(c prometheusCounter) prometheus.Collector { return c.vec })
+}
+
+func (m *PrometheusMetrics) Gauge(name string, help string, labels ...string) f.Gauge {
+	return register(m, name, func() prometheusGauge {
+		return prometheusGauge{prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)}
+	}, func(g prometheusGauge) prometheus.Collector { return g.vec })
+}
+
+func (m *PrometheusMetrics) Histogram(name string, help string, buckets []float64, labels ...string) f.Histogram {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return register(m, name, func() prometheusHistogram {
+		return prometheusHistogram{prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)}
+	}, func(h prometheusHistogram) prometheus.Collector { return h.vec })
+}
+
+// register returns the metric already known under name, or creates and registers it
+func register[T any](m *PrometheusMetrics, name string, create func() T, collector func(T) prometheus.Collector) T {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.collectors[name]; ok {
+		if metric, ok := existing.(T); ok {
+			return metric
+		}
+		log.Warn("[metrics] %s is already registered with another type", name)
+	}
+	metric := create()
+	if err := m.registry.Register(collector(metric)); err != nil {
+		log.Warn("[metrics] failed to register %s: %v", name, err)
+	}
+	m.collectors[name] = metric
+	return metric
+}
+
+type prometheusCounter struct {
+	vec *prometheus.CounterVec
+}
+
+func (c prometheusCounter) Inc(labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Inc()
+}
+
+func (c prometheusCounter) Add(value float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(value)
+}
+
+type prometheusGauge struct {
+	vec *prometheus.GaugeVec
+}
+
+func (g prometheusGauge) Set(value float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Set(value)
+}
+
+func (g prometheusGauge) Inc(labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Inc()
+}
+
+func (g prometheusGauge) Dec(labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Dec()
+}
+
+func (g prometheusGauge) Add(value float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Add(value)
+}
+
+type prometheusHistogram struct {
+	vec *prometheus.HistogramVec
+}
+
+func (h prometheusHistogram) Observe(value float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(value)
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// BUILT-IN INSTRUMENTATION
+// ------------------------------------------------------------------------------------------------------------------
+
+// instruments are the metrics recorded by the adapters themselves
+type instruments struct {
+	httpRequests     f.Counter
+	httpDuration     f.Histogram
+	dbQueryDuration  f.Histogram
+	dbQueryErrors    f.Counter
+	cacheRequests    f.Counter
+	pubsubPublished  f.Counter
+	pubsubReceived   f.Counter
+	queueEnqueued    f.Counter
+	queueJobs        f.Counter
+	queueJobDuration f.Histogram
+}
+
+// noopInstruments are recorded by the adapters until UseMetrics is called on them
+var noopInstruments = newInstruments(f.NoopMetrics{})
+
+func newInstruments(m f.Metrics) *instruments {
+	return &instruments{
+		httpRequests:     m.Counter("http_requests_total", "HTTP requests by method, route template and status", "method", "route", "status"),
+		httpDuration:     m.Histogram("http_request_duration_seconds", "HTTP request latency by method, route template and status", nil, "method", "route", "status"),
+		dbQueryDuration:  m.Histogram("db_query_duration_seconds", "Database query duration by tenant and operation", nil, "tenant", "operation"),
+		dbQueryErrors:    m.Counter("db_query_errors_total", "Failed database queries by tenant and operation", "tenant", "operation"),
+		cacheRequests:    m.Counter("cache_requests_total", "Cache lookups by result (hit or miss)", "result"),
+		pubsubPublished:  m.Counter("pubsub_messages_published_total", "Messages published by topic", "topic"),
+		pubsubReceived:   m.Counter("pubsub_messages_received_total", "Messages received by topic", "topic"),
+		queueEnqueued:    m.Counter("queue_jobs_enqueued_total", "Jobs enqueued by type and status", "type", "status"),
+		queueJobs:        m.Counter("queue_jobs_processed_total", "Jobs processed by type and status", "type", "status"),
+		queueJobDuration: m.Histogram("queue_job_duration_seconds", "Job processing duration by type", nil, "type"),
+	}
+}
+
+// instrumentsOf returns the built-in instruments recorded into m, nil records nowhere
+func instrumentsOf(m f.Metrics) *instruments {
+	if m == nil {
+		return noopInstruments
+	}
+	return newInstruments(m)
+}
+
+// instrumentation is embedded by the adapters recording the built-in metrics, each adapter
+// records into the metrics of its own app.
+type instrumentation struct {
+	instruments *instruments
+}
+
+// UseMetrics records the built-in metrics of the adapter into m
+func (i *instrumentation) UseMetrics(m f.Metrics) {
+	i.instruments = instrumentsOf(m)
+}
+
+func (i instrumentation) metrics() *instruments {
+	if i.instruments == nil {
+		return noopInstruments
+	}
+	return i.instruments
+}
+
+// Instrument records the built-in metrics of the given adapters into m, the adapters
+// without built-in metrics are left untouched.
+func Instrument(m f.Metrics, components ...any) {
+	for _, component := range components {
+		if instrumented, ok := component.(interface{ UseMetrics(f.Metrics) }); ok {
+			instrumented.UseMetrics(m)
+		}
+	}
+}
+
+func seconds(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,101 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+func scrape(t *testing.T, metrics f.Metrics) string {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestPrometheusMetrics_CustomMetrics(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	metrics := NewPrometheusMetrics()
+	metrics.Counter("invoices_sent_total", "Invoices sent", "channel").Inc("email")
+	// same name returns the registered metric
+	metrics.Counter("invoices_sent_total", "Invoices sent", "channel").Add(2, "email")
+	metrics.Gauge("workers_busy", "Busy workers").Set(3)
+	metrics.Histogram("invoice_amount", "Invoice amounts", []float64{10, 100}).Observe(42)
+
+	body := scrape(t, metrics)
+	assert.True(strings.Contains(body, `invoices_sent_total{channel="email"} 3`))
+	assert.True(strings.Contains(body, `workers_busy 3`))
+	assert.True(strings.Contains(body, `invoice_amount_bucket{le="100"} 1`))
+	assert.True(strings.Contains(body, `go_goroutines`))
+}
+
+func TestBuiltinInstrumentation(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	metrics := NewPrometheusMetrics()
+
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", Metrics: metrics})
+	router.Init()
+	router.GET("/items/:id", noopHandler)
+	serve(router, http.MethodGet, "/items/42", "")
+	serve(router, http.MethodGet, "/unknown", "")
+
+	cache := NewInMemoryCacheProvider()
+	Instrument(metrics, cache)
+	_ = cache.Set(context.Background(), "key", "value", 0)
+	_, _ = cache.Get(context.Background(), "key")
+	_, _ = cache.Get(context.Background(), "missing")
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: test.TestDatabaseURL()}).UseMetrics(metrics)
+	assert.Nil(ds.Init(nil))
+	defer ds.Close()
+	assert.Nil(ds.DefaultConnection().Ping())
+
+	pubsub := NewFakePubSubProvider()
+	Instrument(metrics, pubsub)
+	_ = pubsub.Publish(context.Background(), "orders", "{}")
+
+	rec := serve(router, http.MethodGet, "/metrics", "")
+	assert.Equals(rec.Code, http.StatusOK)
+	body := rec.Body.String()
+	assert.True(strings.Contains(body, `http_requests_total{method="GET",route="/items/:id",status="200"} 1`))
+	assert.True(strings.Contains(body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`))
+	assert.True(strings.Contains(body, `cache_requests_total{result="hit"} 1`))
+	assert.True(strings.Contains(body, `cache_requests_total{result="miss"} 1`))
+	assert.True(strings.Contains(body, `pubsub_messages_published_total{topic="orders"} 1`))
+	assert.True(strings.Contains(body, `db_query_duration_seconds_count{operation="select",tenant="default"}`))
+}
+
+func TestBuiltinInstrumentation_PerApp(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	// two apps in the same process, each recording into its own registry
+	first, second := NewPrometheusMetrics(), NewPrometheusMetrics()
+	firstCache, secondCache := NewInMemoryCacheProvider(), NewInMemoryCacheProvider()
+	Instrument(first, firstCache)
+	Instrument(second, secondCache)
+	firstRouter := NewEchoRouter(EchoRouterConfig{Env: "test", Metrics: first})
+	firstRouter.Init()
+	firstRouter.GET("/items/:id", noopHandler)
+	secondRouter := NewEchoRouter(EchoRouterConfig{Env: "test", Metrics: second})
+	secondRouter.Init()
+
+	serve(firstRouter, http.MethodGet, "/items/42", "")
+	_, _ = firstCache.Get(context.Background(), "missing")
+	_, _ = secondCache.Get(context.Background(), "missing")
+	_, _ = secondCache.Get(context.Background(), "missing")
+
+	firstBody, secondBody := scrape(t, first), scrape(t, second)
+	assert.True(strings.Contains(firstBody, `http_requests_total{method="GET",route="/items/:id",status="200"} 1`))
+	assert.False(strings.Contains(secondBody, `route="/items/:id"`))
+	assert.True(strings.Contains(firstBody, `cache_requests_total{result="miss"} 1`))
+	assert.True(strings.Contains(secondBody, `cache_requests_total{result="miss"} 2`))
+}
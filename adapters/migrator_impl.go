@@ -0,0 +1,568 @@
+package adapters
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/h"
+	"github.com/soffa-projects/foundation-go/log"
+	"github.com/uptrace/bun"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// MIGRATIONS
+// ------------------------------------------------------------------------------------------------------------------
+
+func migrationDirs(shared bool) []string {
+	if shared {
+		return []string{"resources/db/migrations/shared", "db/migrations/shared"}
+	}
+	return []string{"resources/db/migrations/tenant", "db/migrations/tenant"}
+}
+
+func changeLogTable(prefix string) string {
+	if prefix != "" {
+		return fmt.Sprintf("%s_database_changelog", strings.TrimSuffix(prefix, "_"))
+	}
+	return "database_changelog"
+}
+
+// migrationSources are the SQL migration filesystems and the Go migrations of the features
+type migrationSources struct {
+	fs    []fs.FS
+	funcs []f.GoMigration
+}
+
+func (s migrationSources) empty() bool {
+	return len(s.fs) == 0 && len(s.funcs) == 0
+}
+
+type migrationFile struct {
+	fsys fs.FS
+	path string
+}
+
+// migrationFS exposes the SQL migrations of every migration directory of every feature
+// as a single flat directory, the layout goose expects.
+type migrationFS map[string]migrationFile
+
+// Two migrations with the same name are an error, goose could only apply one of them.
+func newMigrationFS(migrationsFS []fs.FS, dirs []string) (migrationFS, error) {
+	files := migrationFS{}
+	for _, fsys := range migrationsFS {
+		if fsys == nil {
+			continue
+		}
+		for _, dir := range dirs {
+			entries, err := fs.ReadDir(fsys, dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+					continue
+				}
+				file := migrationFile{fsys: fsys, path: path.Join(dir, entry.Name())}
+				if existing, ok := files[entry.Name()]; ok {
+					// features sharing the same filesystem list the same files
+					if same, err := existing.sameContent(file); err != nil || !same {
+						return nil, fmt.Errorf("duplicate migration %s in %s and %s", entry.Name(), existing.path, file.path)
+					}
+					continue
+				}
+				files[entry.Name()] = file
+			}
+		}
+	}
+	return files, nil
+}
+
+func (m migrationFile) sameContent(other migrationFile) (bool, error) {
+	data, err := fs.ReadFile(m.fsys, m.path)
+	if err != nil {
+		return false, err
+	}
+	otherData, err := fs.ReadFile(other.fsys, other.path)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(data, otherData), nil
+}
+
+func (m migrationFS) Open(name string) (fs.File, error) {
+	file, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return file.fsys.Open(file.path)
+}
+
+func (m migrationFS) Glob(pattern string) ([]string, error) {
+	var names []string
+	for name := range m {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// migrations returns the goose provider of the migrations of t, nil when there is none.
+// Migrations are applied out of order so features can add migrations older than the
+// latest applied one.
+func (t connectionImpl) migrations(sources migrationSources, prefix string) (*goose.Provider, migrationFS, error) {
+	files, err := newMigrationFS(sources.fs, migrationDirs(t.Default))
+	if err != nil {
+		return nil, nil, err
+	}
+	var funcs []*goose.Migration
+	for _, migration := range sources.funcs {
+		if migration.Tenant != t.Default {
+			funcs = append(funcs, t.goMigration(migration))
+		}
+	}
+	if len(files) == 0 && len(funcs) == 0 {
+		return nil, nil, nil
+	}
+	db, ok := t.db.(*bun.DB)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot migrate %s within a transaction", t.Id)
+	}
+	store, err := database.NewStore(database.Dialect(t.dialect), changeLogTable(prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectCustom, db.DB, files,
+		goose.WithStore(store),
+		goose.WithAllowOutofOrder(true),
+		goose.WithDisableGlobalRegistry(true),
+		goose.WithGoMigrations(funcs...),
+	)
+	if errors.Is(err, goose.ErrNoMigrations) {
+		return nil, nil, nil
+	}
+	return provider, files, err
+}
+
+// prepareSchema creates the postgres schema of t, the search path of its connections
+func (t connectionImpl) prepareSchema(ctx context.Context) error {
+	if t.dialect != "postgres" {
+		return nil
+	}
+	schema := "public"
+	if t.schema != "" {
+		schema = t.schema
+	}
+	if _, err := t.db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", schema)); err != nil {
+		return fmt.Errorf("failed to create schema %s: %v", schema, err)
+	}
+	return nil
+}
+
+// migrate applies the pending migrations of t
+func (t connectionImpl) migrate(ctx context.Context, sources migrationSources, prefix string) error {
+	provider, _, err := t.migrations(sources, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations for %s: %v", t.Id, err)
+	}
+	if provider == nil {
+		return nil
+	}
+	if err := t.prepareSchema(ctx); err != nil {
+		return err
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		return fmt.Errorf("failed to run migrations for %s: %v", t.Id, err)
+	}
+	log.Info("migrations completed for tenant: %s", t.Id)
+	return nil
+}
+
+// goMigration runs migration with a connection bound to the transaction goose records its version in
+func (t connectionImpl) goMigration(migration f.GoMigration) *goose.Migration {
+	run := func(fn func(ctx context.Context, cnx f.Connection) error) *goose.GoFunc {
+		if fn == nil {
+			return nil
+		}
+		return &goose.GoFunc{RunTx: func(ctx context.Context, tx *sql.Tx) error {
+			cnx := t
+			cnx.db = migrationTx{DB: t.db.(*bun.DB), tx: tx}
+			cnx.transaction = true
+			if t.Default {
+				ctx = context.WithValue(ctx, f.DefaultCnxKey{}, cnx)
+			} else {
+				ctx = context.WithValue(ctx, f.TenantKey{}, t.Id)
+				ctx = context.WithValue(ctx, f.TenantCnxKey{}, cnx)
+			}
+			return fn(ctx, cnx)
+		}}
+	}
+	name := cmp.Or(migration.Name, "migration")
+	m := goose.NewGoMigration(migration.Version, run(migration.Up), run(migration.Down))
+	m.Source = fmt.Sprintf("%d_%s.go", migration.Version, name)
+	return m
+}
+
+// migrationSQL returns the up or down section of a goose SQL migration
+func migrationSQL(files migrationFS, name string, up bool) (string, error) {
+	if !strings.HasSuffix(name, ".sql") {
+		return "-- go migration", nil
+	}
+	data, err := fs.ReadFile(files, name)
+	if err != nil {
+		return "", err
+	}
+	var (
+		lines   []string
+		section string
+	)
+	for _, line := range strings.Split(string(data), "\n") {
+		if annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose"); ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				section = "up"
+			case "Down":
+				section = "down"
+			}
+			continue
+		}
+		if section != "" && (section == "up") == up {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// MIGRATOR IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+type MigratorImpl struct {
+	f.Migrator
+	ds         *MultiTenantDataSource
+	migrations migrationSources
+}
+
+type migrationTarget struct {
+	id     string
+	slug   string
+	url    string
+	shared bool
+	prefix string
+}
+
+// NewMigrator runs migration commands on the databases of ds with connections of its
+// own, ds does not have to be initialized.
+func NewMigrator(ds *MultiTenantDataSource, features []f.Feature) *MigratorImpl {
+	return &MigratorImpl{ds: ds, migrations: ds.migrationSources(features)}
+}
+
+func (m *MigratorImpl) Migrate(ctx context.Context, cmd f.MigrationCommand) ([]f.MigrationReport, error) {
+	targets, err := m.targets(ctx, cmd.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		reports []f.MigrationReport
+		errs    []error
+	)
+	for _, target := range targets {
+		report := m.run(ctx, target, cmd)
+		reports = append(reports, report)
+		if report.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.id, report.Err))
+			if !cmd.ContinueOnError {
+				break
+			}
+		}
+	}
+	return reports, errors.Join(errs...)
+}
+
+func (m *MigratorImpl) targets(ctx context.Context, tenants []string) ([]migrationTarget, error) {
+	var targets []migrationTarget
+	if m.ds.cfg.DatabaseUrl != "" {
+		targets = append(targets, migrationTarget{id: _defaultTenantId, url: m.ds.cfg.DatabaseUrl, shared: true, prefix: m.ds.cfg.Prefix})
+	}
+	if m.ds.cfg.Strategy == f.DiscriminatorStrategy {
+		// the tenants share the tenant tables of the default database
+		targets = append(targets, migrationTarget{id: _sharedTenantsId, url: m.ds.cfg.DatabaseUrl, prefix: m.ds.tenantTablesPrefix()})
+	} else if m.ds.tenantProvider != nil {
+		tenantList, err := m.ds.tenantProvider.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tenants: %v", err)
+		}
+		for _, tenant := range tenantList {
+			targets = append(targets, migrationTarget{id: tenant.ID, slug: tenant.Slug, url: m.ds.tenantUrl(tenant), prefix: m.ds.cfg.Prefix})
+		}
+	}
+	if len(tenants) == 0 {
+		return targets, nil
+	}
+	var selected []migrationTarget
+	for _, name := range tenants {
+		index := slices.IndexFunc(targets, func(target migrationTarget) bool {
+			return target.id == name || (target.slug != "" && target.slug == name)
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("unknown tenant: %s", name)
+		}
+		selected = append(selected, targets[index])
+	}
+	return selected, nil
+}
+
+func (m *MigratorImpl) run(ctx context.Context, target migrationTarget, cmd f.MigrationCommand) f.MigrationReport {
+	report := f.MigrationReport{Tenant: target.id}
+	cnx := connectionImpl{Id: target.id, Url: target.url, Default: target.shared}
+	if err := cnx.configure(ctx, migrationSources{}, target.prefix); err != nil {
+		report.Err = err
+		return report
+	}
+	defer cnx.Close()
+
+	provider, files, err := cnx.migrations(m.migrations, target.prefix)
+	if err != nil {
+		report.Err = fmt.Errorf("failed to load migrations: %v", err)
+		return report
+	}
+	if provider == nil {
+		return report
+	}
+	if err := cnx.prepareSchema(ctx); err != nil {
+		report.Err = err
+		return report
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	if cmd.Action == f.MigrateStatus {
+		for _, status := range statuses {
+			report.Migrations = append(report.Migrations, f.MigrationStatus{
+				Version:   status.Source.Version,
+				Name:      filepath.Base(status.Source.Path),
+				Applied:   status.State == goose.StateApplied,
+				AppliedAt: status.AppliedAt,
+			})
+		}
+	} else {
+		report.Steps, report.Err = m.apply(ctx, provider, files, statuses, cmd)
+	}
+	if report.Version, err = provider.GetDBVersion(ctx); err != nil && report.Err == nil {
+		report.Err = err
+	}
+	return report
+}
+
+// apply runs the steps planned for cmd one migration at a time, dry runs only read their SQL
+func (m *MigratorImpl) apply(ctx context.Context, provider *goose.Provider, files migrationFS, statuses []*goose.MigrationStatus, cmd f.MigrationCommand) ([]f.MigrationStep, error) {
+	plan, err := planMigration(statuses, cmd)
+	if err != nil {
+		return nil, err
+	}
+	var steps []f.MigrationStep
+	for _, step := range plan {
+		if cmd.DryRun {
+			if step.SQL, err = migrationSQL(files, step.Name, step.Direction == "up"); err != nil {
+				return steps, err
+			}
+			steps = append(steps, step)
+			continue
+		}
+		result, err := provider.ApplyVersion(ctx, step.Version, step.Direction == "up")
+		if err != nil {
+			return steps, fmt.Errorf("%s %s: %v", step.Direction, step.Name, err)
+		}
+		step.Duration = result.Duration
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func planMigration(statuses []*goose.MigrationStatus, cmd f.MigrationCommand) ([]f.MigrationStep, error) {
+	var pending, applied []*goose.MigrationStatus
+	for _, status := range statuses {
+		if status.State == goose.StateApplied {
+			applied = append(applied, status)
+		} else {
+			pending = append(pending, status)
+		}
+	}
+	// rollbacks undo the last applied migrations first
+	slices.SortStableFunc(applied, func(a, b *goose.MigrationStatus) int {
+		if c := b.AppliedAt.Compare(a.AppliedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Source.Version, a.Source.Version)
+	})
+	step := func(status *goose.MigrationStatus, direction string) f.MigrationStep {
+		return f.MigrationStep{
+			Version:   status.Source.Version,
+			Name:      filepath.Base(status.Source.Path),
+			Direction: direction,
+		}
+	}
+
+	var steps []f.MigrationStep
+	switch cmd.Action {
+	case f.MigrateUp, f.MigrateUpTo:
+		for _, status := range pending {
+			if cmd.Action == f.MigrateUp || status.Source.Version <= cmd.Version {
+				steps = append(steps, step(status, "up"))
+			}
+		}
+	case f.MigrateDown:
+		for _, status := range applied[:min(max(cmd.Steps, 1), len(applied))] {
+			steps = append(steps, step(status, "down"))
+		}
+	case f.MigrateDownTo:
+		for _, status := range applied {
+			if status.Source.Version > cmd.Version {
+				steps = append(steps, step(status, "down"))
+			}
+		}
+	case f.MigrateRedo:
+		if len(applied) == 0 {
+			return nil, fmt.Errorf("no migration to redo")
+		}
+		steps = append(steps, step(applied[0], "down"), step(applied[0], "up"))
+	default:
+		return nil, fmt.Errorf("unsupported migration action: %s", cmd.Action)
+	}
+	return steps, nil
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// MIGRATION TX
+// ------------------------------------------------------------------------------------------------------------------
+
+// migrationTx runs the queries of a bun database in a transaction bun did not begin, the
+// nested transactions of the connections bound to it are savepoints, see beginTx.
+type migrationTx struct {
+	*bun.DB
+	tx *sql.Tx
+}
+
+var errNestedMigrationTx = errors.New("go migrations already run in a transaction, use Connection.Transaction for a savepoint")
+
+func (m migrationTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return m.tx.ExecContext(ctx, query, args...)
+}
+
+func (m migrationTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return m.tx.QueryContext(ctx, query, args...)
+}
+
+func (m migrationTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return m.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (m migrationTx) NewValues(model any) *bun.ValuesQuery {
+	return m.DB.NewValues(model).Conn(m.tx)
+}
+
+func (m migrationTx) NewSelect() *bun.SelectQuery {
+	return m.DB.NewSelect().Conn(m.tx)
+}
+
+func (m migrationTx) NewInsert() *bun.InsertQuery {
+	return m.DB.NewInsert().Conn(m.tx)
+}
+
+func (m migrationTx) NewUpdate() *bun.UpdateQuery {
+	return m.DB.NewUpdate().Conn(m.tx)
+}
+
+func (m migrationTx) NewDelete() *bun.DeleteQuery {
+	return m.DB.NewDelete().Conn(m.tx)
+}
+
+func (m migrationTx) NewMerge() *bun.MergeQuery {
+	return m.DB.NewMerge().Conn(m.tx)
+}
+
+func (m migrationTx) NewRaw(query string, args ...any) *bun.RawQuery {
+	return m.DB.NewRaw(query, args...).Conn(m.tx)
+}
+
+func (m migrationTx) NewCreateTable() *bun.CreateTableQuery {
+	return m.DB.NewCreateTable().Conn(m.tx)
+}
+
+func (m migrationTx) NewDropTable() *bun.DropTableQuery {
+	return m.DB.NewDropTable().Conn(m.tx)
+}
+
+func (m migrationTx) NewCreateIndex() *bun.CreateIndexQuery {
+	return m.DB.NewCreateIndex().Conn(m.tx)
+}
+
+func (m migrationTx) NewDropIndex() *bun.DropIndexQuery {
+	return m.DB.NewDropIndex().Conn(m.tx)
+}
+
+func (m migrationTx) NewTruncateTable() *bun.TruncateTableQuery {
+	return m.DB.NewTruncateTable().Conn(m.tx)
+}
+
+func (m migrationTx) NewAddColumn() *bun.AddColumnQuery {
+	return m.DB.NewAddColumn().Conn(m.tx)
+}
+
+func (m migrationTx) NewDropColumn() *bun.DropColumnQuery {
+	return m.DB.NewDropColumn().Conn(m.tx)
+}
+
+func (m migrationTx) BeginTx(ctx context.Context, opts *sql.TxOptions) (bun.Tx, error) {
+	return bun.Tx{}, errNestedMigrationTx
+}
+
+func (m migrationTx) RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error) error {
+	return errNestedMigrationTx
+}
+
+// savepoint begins a savepoint of the migration transaction, the nested transactions of
+// go migrations run in one.
+func (m migrationTx) savepoint(ctx context.Context) (migrationSavepoint, error) {
+	name := "sp_" + strings.ToLower(h.RandomString(10))
+	if _, err := m.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return migrationSavepoint{}, err
+	}
+	return migrationSavepoint{migrationTx: m, name: name}, nil
+}
+
+// migrationSavepoint is a savepoint of a migration transaction, committing it releases
+// the savepoint and leaves the transaction to goose.
+type migrationSavepoint struct {
+	migrationTx
+	name string
+}
+
+func (s migrationSavepoint) Commit() error {
+	_, err := s.tx.Exec("RELEASE SAVEPOINT " + s.name)
+	return err
+}
+
+func (s migrationSavepoint) Rollback() error {
+	_, err := s.tx.Exec("ROLLBACK TO SAVEPOINT " + s.name)
+	return err
+}
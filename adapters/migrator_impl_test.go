@@ -0,0 +1,292 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+func createTableMigration(table string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(fmt.Sprintf(
+		"-- +goose Up\nCREATE TABLE %s (id TEXT PRIMARY KEY);\n\n-- +goose Down\nDROP TABLE %s;\n", table, table,
+	))}
+}
+
+func newTestMigrator(t *testing.T, tenants ...f.Tenant) *MigratorImpl {
+	dir := t.TempDir()
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: "sqlite://" + filepath.Join(dir, "default.db"),
+	})
+	for i := range tenants {
+		if tenants[i].DatabaseUrl == "" {
+			tenants[i].DatabaseUrl = "sqlite://" + filepath.Join(dir, tenants[i].ID+".db")
+		}
+	}
+	ds.UseTenantProvider(&mockTenantProvider{tenants: tenants})
+	return NewMigrator(ds, []f.Feature{{
+		Name: "test",
+		FS: fstest.MapFS{
+			"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+			"db/migrations/shared/002_users.sql":    createTableMigration("users"),
+			"db/migrations/tenant/001_items.sql":    createTableMigration("items"),
+		},
+	}})
+}
+
+func TestMigrator_StatusAndUp(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	migrator := newTestMigrator(t, f.Tenant{ID: "t1", Slug: "acme"})
+
+	reports, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateStatus})
+	assert.Nil(err)
+	assert.Equals(len(reports), 2)
+	assert.Equals(reports[0].Tenant, "default")
+	assert.Equals(len(reports[0].Migrations), 2)
+	assert.False(reports[0].Migrations[0].Applied)
+	assert.Equals(reports[1].Tenant, "t1")
+	assert.Equals(reports[1].Migrations[0].Name, "001_items.sql")
+
+	reports, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUpTo, Version: 1})
+	assert.Nil(err)
+	assert.Equals(reports[0].Version, int64(1))
+	assert.Equals(len(reports[0].Steps), 1)
+	assert.Equals(reports[1].Version, int64(1))
+
+	reports, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.Nil(err)
+	assert.Equals(reports[0].Version, int64(2))
+	assert.Equals(reports[0].Steps[0].Name, "002_users.sql")
+	assert.Equals(len(reports[1].Steps), 0)
+
+	reports, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateStatus, Tenants: []string{"acme"}})
+	assert.Nil(err)
+	assert.Equals(len(reports), 1)
+	assert.True(reports[0].Migrations[0].Applied)
+}
+
+func TestMigrator_DownAndRedo(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	migrator := newTestMigrator(t)
+
+	_, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.Nil(err)
+
+	reports, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateRedo})
+	assert.Nil(err)
+	assert.Equals(len(reports[0].Steps), 2)
+	assert.Equals(reports[0].Steps[0].Direction, "down")
+	assert.Equals(reports[0].Steps[1].Direction, "up")
+	assert.Equals(reports[0].Version, int64(2))
+
+	reports, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateDown, Steps: 2})
+	assert.Nil(err)
+	assert.Equals(len(reports[0].Steps), 2)
+	assert.Equals(reports[0].Steps[0].Name, "002_users.sql")
+	assert.Equals(reports[0].Version, int64(0))
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	migrator := newTestMigrator(t)
+
+	reports, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp, DryRun: true})
+	assert.Nil(err)
+	assert.Equals(len(reports[0].Steps), 2)
+	assert.Equals(reports[0].Steps[0].SQL, "CREATE TABLE accounts (id TEXT PRIMARY KEY);")
+	assert.Equals(reports[0].Version, int64(0))
+
+	_, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.Nil(err)
+	reports, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateDown, DryRun: true})
+	assert.Nil(err)
+	assert.Equals(reports[0].Steps[0].SQL, "DROP TABLE users;")
+	assert.Equals(reports[0].Version, int64(2))
+}
+
+func TestMigrator_ContinueOnError(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	tenants := []f.Tenant{{ID: "broken", DatabaseUrl: "unsupported://db"}, {ID: "t2"}}
+
+	reports, err := newTestMigrator(t, tenants...).Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.NotNil(err)
+	assert.Equals(len(reports), 2)
+	assert.NotNil(reports[1].Err)
+
+	reports, err = newTestMigrator(t, tenants...).Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp, ContinueOnError: true})
+	assert.True(strings.Contains(err.Error(), "broken"))
+	assert.Equals(len(reports), 3)
+	assert.Nil(reports[2].Err)
+	assert.Equals(reports[2].Version, int64(1))
+}
+
+func TestMigrator_UnknownTenant(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	_, err := newTestMigrator(t).Migrate(context.Background(), f.MigrationCommand{Action: f.MigrateUp, Tenants: []string{"nope"}})
+	assert.NotNil(err)
+}
+
+type account struct {
+	f.Entity `bun:"table:accounts"`
+	ID       string `bun:",pk"`
+}
+
+func accountsBackfill(version int64, fail bool) f.GoMigration {
+	return f.GoMigration{
+		Version: version,
+		Name:    "backfill_accounts",
+		Up: func(ctx context.Context, cnx f.Connection) error {
+			if err := f.NewDefaultRepository[account]().Insert(ctx, &account{ID: "system"}); err != nil {
+				return err
+			}
+			if fail {
+				return fmt.Errorf("backfill failed")
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, cnx f.Connection) error {
+			return cnx.DeleteBy(ctx, &account{}, "id = ?", "system")
+		},
+	}
+}
+
+func TestMigrator_GoMigrations(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	migrator := newTestMigrator(t, f.Tenant{ID: "t1"})
+	migrator.migrations.funcs = append(migrator.migrations.funcs, accountsBackfill(3, false))
+
+	reports, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateStatus})
+	assert.Nil(err)
+	assert.Equals(len(reports[0].Migrations), 3)
+	assert.Equals(reports[0].Migrations[2].Name, "3_backfill_accounts.go")
+	// tenant databases only run tenant go migrations
+	assert.Equals(len(reports[1].Migrations), 1)
+
+	reports, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.Nil(err)
+	assert.Equals(reports[0].Version, int64(3))
+	assert.Equals(len(reports[0].Steps), 3)
+
+	cnx, err := NewConnection(migrator.ds.cfg.DatabaseUrl)
+	assert.Nil(err)
+	count, err := cnx.Count(ctx, &account{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+
+	_, err = migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateDown})
+	assert.Nil(err)
+	count, err = cnx.Count(ctx, &account{})
+	assert.Nil(err)
+	assert.Equals(count, 0)
+}
+
+func TestMigrator_GoMigrationRollsBack(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	migrator := newTestMigrator(t)
+	migrator.migrations.funcs = append(migrator.migrations.funcs, accountsBackfill(2, true))
+	migrator.migrations.fs = []fs.FS{fstest.MapFS{
+		"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+	}}
+	reports, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.NotNil(err)
+	assert.Equals(reports[0].Version, int64(1))
+
+	cnx, err := NewConnection(migrator.ds.cfg.DatabaseUrl)
+	assert.Nil(err)
+	count, err := cnx.Count(ctx, &account{})
+	assert.Nil(err)
+	assert.Equals(count, 0)
+}
+
+func TestMigrator_GoMigrationNestedTransactions(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	migrator := newTestMigrator(t)
+	migrator.migrations.fs = []fs.FS{fstest.MapFS{
+		"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+	}}
+	migrator.migrations.funcs = append(migrator.migrations.funcs, f.GoMigration{
+		Version: 2,
+		Name:    "nested",
+		Up: func(ctx context.Context, cnx f.Connection) error {
+			// nested transactions are savepoints of the migration one
+			err := cnx.Transaction(ctx, func(tx f.Connection) error {
+				return tx.Insert(ctx, &account{ID: "kept"})
+			})
+			if err != nil {
+				return err
+			}
+			err = cnx.Transaction(ctx, func(tx f.Connection) error {
+				if err := tx.Insert(ctx, &account{ID: "rolled-back"}); err != nil {
+					return err
+				}
+				return fmt.Errorf("rolled back")
+			})
+			if err == nil {
+				return fmt.Errorf("the failed transaction did not fail")
+			}
+			return nil
+		},
+	})
+	_, err := migrator.Migrate(ctx, f.MigrationCommand{Action: f.MigrateUp})
+	assert.Nil(err)
+
+	cnx, err := NewConnection(migrator.ds.cfg.DatabaseUrl)
+	assert.Nil(err)
+	var accounts []account
+	_, err = cnx.Query(ctx, &accounts)
+	assert.Nil(err)
+	assert.Equals(accounts, []account{{ID: "kept"}})
+}
+
+func TestMultiTenantDS_Init_RunsGoMigrations(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "default.db"),
+	})
+	err := ds.Init([]f.Feature{{
+		Name: "test",
+		FS: fstest.MapFS{
+			"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+		},
+		Migrations: []f.GoMigration{accountsBackfill(2, false)},
+	}})
+	assert.Nil(err)
+	count, err := ds.DefaultConnection().Count(context.Background(), &account{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+}
+
+func TestMultiTenantDS_Init_RejectsDuplicateMigrations(t *testing.T) {
+	assert := test.NewAssertions(t)
+	shared := fstest.MapFS{
+		"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+	}
+	newDS := func() *MultiTenantDataSource {
+		return NewMultiTenantDS(f.DataSourceConfig{
+			DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "default.db"),
+		})
+	}
+
+	// features sharing a filesystem list the same migrations
+	assert.Nil(newDS().Init([]f.Feature{{Name: "accounts", FS: shared}, {Name: "billing", FS: shared}}))
+
+	err := newDS().Init([]f.Feature{{Name: "accounts", FS: shared}, {Name: "billing", FS: fstest.MapFS{
+		"db/migrations/shared/001_accounts.sql": createTableMigration("invoices"),
+	}}})
+	assert.NotNil(err)
+	assert.True(strings.Contains(err.Error(), "duplicate migration 001_accounts.sql"))
+}
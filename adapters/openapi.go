@@ -0,0 +1,484 @@
+package adapters
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/soffa-projects/foundation-go/h"
+)
+
+const openAPIVersion = "3.1.0"
+const openAPIPath = "/openapi.json"
+
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+var schemaNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+var permissionMiddlewareProbe = f.PermissionMiddleware()
+
+// ------------------------------------------------------------------------------------------------------------------
+// OPENAPI DOCUMENT BUILDER
+// ------------------------------------------------------------------------------------------------------------------
+
+type openAPIBuilder struct {
+	schemas      map[string]any
+	types        map[reflect.Type]string
+	legacyErrors bool
+}
+
+// NewOpenAPIDocument builds an OpenAPI 3.1 document describing the given routes.
+func NewOpenAPIDocument(info f.AppInfo, routes []f.Route) map[string]any {
+	return newOpenAPIDocument(info, routes, false)
+}
+
+func newOpenAPIDocument(info f.AppInfo, routes []f.Route, legacyErrors bool) map[string]any {
+	b := &openAPIBuilder{
+		schemas:      make(map[string]any),
+		types:        make(map[reflect.Type]string),
+		legacyErrors: legacyErrors,
+	}
+	paths := make(map[string]any)
+	for _, route := range routes {
+		path := toOpenAPIPath(route.Path)
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = b.operation(route)
+	}
+
+	if legacyErrors {
+		b.schemas["Error"] = map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"requestId": map[string]any{"type": "string"},
+				"timestamp": map[string]any{"type": "string", "format": "date-time"},
+				"uri":       map[string]any{"type": "string"},
+				"error":     map[string]any{"type": "string"},
+				"success":   map[string]any{"type": "boolean"},
+			},
+		}
+	} else {
+		b.schemas["Error"] = b.structSchema(reflect.TypeOf(errors.ProblemDetails{}), false)
+	}
+
+	title := info.Name
+	if title == "" {
+		title = "API"
+	}
+	version := info.Version
+	if version == "" {
+		version = "0.0.0"
+	}
+	doc := map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+	if info.PublicURL != "" {
+		doc["servers"] = []any{map[string]any{"url": info.PublicURL}}
+	}
+	return doc
+}
+
+func (b *openAPIBuilder) operation(route f.Route) map[string]any {
+	op := map[string]any{
+		"operationId": operationId(route.Method, route.Path),
+	}
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.Description != "" {
+		op["description"] = route.Description
+	}
+	if len(route.Tags) > 0 {
+		op["tags"] = route.Tags
+	}
+
+	pathParams := map[string]bool{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		pathParams[match[1]] = true
+	}
+
+	params := []any{}
+	seen := map[string]bool{}
+	addParam := func(in string, name string, required bool, schema any) {
+		key := in + ":" + strings.ToLower(name)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       in,
+			"required": required,
+			"schema":   schema,
+		})
+	}
+
+	var body map[string]any
+	if route.Input != nil {
+		t := indirectType(reflect.TypeOf(route.Input))
+		if t.Kind() == reflect.Struct {
+			for _, field := range bindingFields(t) {
+				schema := b.schema(field.Type)
+				required := hasValidateRule(field, "required")
+				if name := tagName(field, "param"); name != "" && pathParams[name] {
+					addParam("path", name, true, schema)
+					continue
+				}
+				if name := tagName(field, "query"); name != "" {
+					addParam("query", name, required, schema)
+					continue
+				}
+				if name := tagName(field, "header"); name != "" {
+					addParam("header", name, required, schema)
+					continue
+				}
+			}
+			if hasRequestBody(route.Method) {
+				body = b.structSchema(t, true)
+			}
+		} else if hasRequestBody(route.Method) {
+			body = toMap(b.schema(t))
+		}
+	}
+
+	for name := range pathParams {
+		addParam("path", name, true, map[string]any{"type": "string"})
+	}
+
+	secured := false
+	errorStatuses := []int{http.StatusBadRequest}
+	for _, m := range route.Middlewares {
+		switch {
+		case h.IsSameFunc(m, f.AuthMiddleware):
+			secured = true
+			errorStatuses = append(errorStatuses, http.StatusUnauthorized)
+		case h.IsSameFunc(m, permissionMiddlewareProbe):
+			secured = true
+			errorStatuses = append(errorStatuses, http.StatusUnauthorized, http.StatusForbidden)
+		case h.IsSameFunc(m, f.TenantMiddleware):
+			if !pathParams["tenant"] {
+				addParam("header", "X-TenantId", true, map[string]any{"type": "string"})
+			}
+		}
+	}
+	if secured {
+		op["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+	}
+	if len(params) > 0 {
+		op["parameters"] = sortParams(params)
+	}
+	if body != nil && (len(toMap(body["properties"])) > 0 || body["$ref"] != nil) {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": body},
+			},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if route.Output != nil && status != http.StatusNoContent {
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(route.Output))},
+		}
+	}
+	responses := map[string]any{strconv.Itoa(status): success}
+	errorSchema := map[string]any{"$ref": "#/components/schemas/Error"}
+	errorContentType := problemContentType
+	if b.legacyErrors {
+		errorContentType = "application/json"
+	}
+	errorStatuses = append(errorStatuses, http.StatusInternalServerError)
+	for _, code := range errorStatuses {
+		responses[strconv.Itoa(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content": map[string]any{
+				errorContentType: map[string]any{"schema": errorSchema},
+			},
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// SCHEMA REFLECTION
+// ------------------------------------------------------------------------------------------------------------------
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b *openAPIBuilder) schema(t reflect.Type) any {
+	t = indirectType(t)
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"type": "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t, false)
+		}
+		if name, ok := b.types[t]; ok {
+			return map[string]any{"$ref": "#/components/schemas/" + name}
+		}
+		name := b.schemaName(t)
+		b.types[t] = name
+		b.schemas[name] = b.structSchema(t, false)
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema describes the JSON payload of a struct. When bodyOnly is set, fields
+// bound from the path, query string or headers are left out.
+func (b *openAPIBuilder) structSchema(t reflect.Type, bodyOnly bool) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, field := range bindingFields(t) {
+		if bodyOnly && tagName(field, "json") == "" &&
+			(tagName(field, "param") != "" || tagName(field, "query") != "" || tagName(field, "header") != "") {
+			continue
+		}
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		schema := toMap(b.schema(field.Type))
+		applyValidateRules(schema, field)
+		properties[name] = schema
+		if hasValidateRule(field, "required") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (b *openAPIBuilder) schemaName(t reflect.Type) string {
+	name := schemaNameSanitizer.ReplaceAllString(t.Name(), "_")
+	candidate := name
+	for i := 2; ; i++ {
+		if _, exists := b.schemas[candidate]; !exists {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+}
+
+// bindingFields returns the exported fields of a struct, flattening embedded structs
+// the same way the echo binder and encoding/json do.
+func bindingFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			ft := indirectType(field.Type)
+			if ft.Kind() == reflect.Struct && tagName(field, "json") == "" {
+				fields = append(fields, bindingFields(ft)...)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func applyValidateRules(schema map[string]any, field reflect.StructField) {
+	kind := indirectType(field.Type).Kind()
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri":
+			schema["format"] = "uri"
+		case "uuid", "uuid4":
+			schema["format"] = "uuid"
+		case "oneof":
+			enum := []any{}
+			for _, v := range strings.Fields(value) {
+				enum = append(enum, v)
+			}
+			schema["enum"] = enum
+		case "min", "gte":
+			setBound(schema, kind, "minLength", "minItems", "minimum", value)
+		case "max", "lte":
+			setBound(schema, kind, "maxLength", "maxItems", "maximum", value)
+		case "gt":
+			setBound(schema, kind, "", "", "exclusiveMinimum", value)
+		case "lt":
+			setBound(schema, kind, "", "", "exclusiveMaximum", value)
+		case "len":
+			setBound(schema, kind, "minLength", "minItems", "", value)
+			setBound(schema, kind, "maxLength", "maxItems", "", value)
+		}
+	}
+}
+
+func setBound(schema map[string]any, kind reflect.Kind, stringKey string, arrayKey string, numberKey string, value string) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	switch kind {
+	case reflect.String:
+		if stringKey != "" {
+			schema[stringKey] = int(n)
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if arrayKey != "" {
+			schema[arrayKey] = int(n)
+		}
+	default:
+		if numberKey != "" {
+			schema[numberKey] = n
+		}
+	}
+}
+
+func hasValidateRule(field reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(field.Tag.Get("validate"), ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func tagName(field reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func jsonName(field reflect.StructField) string {
+	value, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name
+	}
+	name, _, _ := strings.Cut(value, ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// COMMON
+// ------------------------------------------------------------------------------------------------------------------
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func toMap(value any) map[string]any {
+	if m, ok := value.(map[string]any); ok {
+		return m
+	}
+	return map[string]any{}
+}
+
+func toOpenAPIPath(path string) string {
+	path = pathParamPattern.ReplaceAllString(path, "{$1}")
+	if strings.HasSuffix(path, "*") {
+		path = strings.TrimSuffix(path, "*") + "{path}"
+	}
+	return path
+}
+
+func operationId(method string, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.TrimPrefix(segment, ":")
+		segment = schemaNameSanitizer.ReplaceAllString(segment, "")
+		if segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func hasRequestBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodDelete, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func sortParams(params []any) []any {
+	order := map[string]int{"path": 0, "query": 1, "header": 2}
+	sort.SliceStable(params, func(i, j int) bool {
+		a, b := params[i].(map[string]any), params[j].(map[string]any)
+		if a["in"] != b["in"] {
+			return order[a["in"].(string)] < order[b["in"].(string)]
+		}
+		return a["name"].(string) < b["name"].(string)
+	})
+	return params
+}
@@ -0,0 +1,168 @@
+package adapters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+type openAPICreateUserInput struct {
+	f.TenantInput
+	Name  string   `json:"name" validate:"required,min=2,max=64"`
+	Email string   `json:"email" validate:"required,email"`
+	Role  string   `json:"role" validate:"oneof=admin user"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type openAPIUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type openAPIGetUserInput struct {
+	ID     string `param:"id" json:"-" validate:"required"`
+	Expand bool   `query:"expand" json:"-"`
+}
+
+func noopHandler(c f.HttpContext) error {
+	return nil
+}
+
+func documentJSON(t *testing.T, doc map[string]any) map[string]any {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal document: %v", err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("failed to unmarshal document: %v", err)
+	}
+	return result
+}
+
+func TestNewOpenAPIDocument_Info(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	doc := documentJSON(t, NewOpenAPIDocument(f.AppInfo{Name: "demo", Version: "1.2.3", PublicURL: "https://api.demo.io"}, nil))
+
+	assert.Equals(doc["openapi"], "3.1.0")
+	info := doc["info"].(map[string]any)
+	assert.Equals(info["title"], "demo")
+	assert.Equals(info["version"], "1.2.3")
+	servers := doc["servers"].([]any)
+	assert.Equals(servers[0].(map[string]any)["url"], "https://api.demo.io")
+}
+
+func TestNewOpenAPIDocument_RequestBodyFromTags(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	doc := documentJSON(t, NewOpenAPIDocument(f.AppInfo{}, []f.Route{{
+		Method:      http.MethodPost,
+		Path:        "/users",
+		Handler:     noopHandler,
+		Middlewares: []f.Middleware{f.AuthMiddleware, f.TenantMiddleware},
+		Input:       openAPICreateUserInput{},
+		Output:      openAPIUser{},
+		Status:      http.StatusCreated,
+	}}))
+
+	op := doc["paths"].(map[string]any)["/users"].(map[string]any)["post"].(map[string]any)
+	assert.Equals(op["operationId"], "post_users")
+	assert.NotNil(op["security"])
+
+	params := op["parameters"].([]any)
+	assert.Equals(len(params), 1)
+	assert.Equals(params[0].(map[string]any)["name"], "X-TenantId")
+	assert.Equals(params[0].(map[string]any)["in"], "header")
+	assert.Equals(params[0].(map[string]any)["required"], true)
+
+	schema := op["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	props := schema["properties"].(map[string]any)
+	assert.Equals(len(props), 4)
+	assert.Equals(props["email"].(map[string]any)["format"], "email")
+	assert.Equals(props["name"].(map[string]any)["minLength"], float64(2))
+	assert.Equals(props["name"].(map[string]any)["maxLength"], float64(64))
+	assert.Equals(props["role"].(map[string]any)["enum"], []any{"admin", "user"})
+	assert.Equals(props["tags"].(map[string]any)["type"], "array")
+	assert.Equals(schema["required"], []any{"name", "email"})
+
+	responses := op["responses"].(map[string]any)
+	created := responses["201"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	assert.Equals(created["$ref"], "#/components/schemas/openAPIUser")
+	assert.NotNil(responses["401"])
+
+	components := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	assert.NotNil(components["openAPIUser"])
+}
+
+func TestNewOpenAPIDocument_PathAndQueryParams(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	doc := documentJSON(t, NewOpenAPIDocument(f.AppInfo{}, []f.Route{{
+		Method:      http.MethodGet,
+		Path:        "/users/:id",
+		Handler:     noopHandler,
+		Middlewares: []f.Middleware{f.PermissionMiddleware("admin")},
+		Input:       openAPIGetUserInput{},
+	}}))
+
+	op := doc["paths"].(map[string]any)["/users/{id}"].(map[string]any)["get"].(map[string]any)
+	_, hasBody := op["requestBody"]
+	assert.False(hasBody)
+	assert.NotNil(op["security"])
+	assert.NotNil(op["responses"].(map[string]any)["403"])
+
+	params := op["parameters"].([]any)
+	assert.Equals(len(params), 2)
+	assert.Equals(params[0].(map[string]any)["in"], "path")
+	assert.Equals(params[0].(map[string]any)["name"], "id")
+	assert.Equals(params[1].(map[string]any)["in"], "query")
+	assert.Equals(params[1].(map[string]any)["name"], "expand")
+	assert.Equals(params[1].(map[string]any)["schema"].(map[string]any)["type"], "boolean")
+}
+
+func TestNewOpenAPIDocument_TenantPathParam(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	doc := documentJSON(t, NewOpenAPIDocument(f.AppInfo{}, []f.Route{{
+		Method:      http.MethodPost,
+		Path:        "/:tenant/users",
+		Handler:     noopHandler,
+		Middlewares: []f.Middleware{f.TenantMiddleware},
+		Input:       openAPICreateUserInput{},
+	}}))
+
+	op := doc["paths"].(map[string]any)["/{tenant}/users"].(map[string]any)["post"].(map[string]any)
+	params := op["parameters"].([]any)
+	assert.Equals(len(params), 1)
+	assert.Equals(params[0].(map[string]any)["in"], "path")
+	assert.Equals(params[0].(map[string]any)["name"], "tenant")
+}
+
+func TestEchoRouter_ServesOpenAPIDocument(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", AppInfo: f.AppInfo{Name: "demo", Version: "1.0.0"}})
+	router.Init()
+	router.GET("/ping", noopHandler)
+	group := router.Group("/admin", f.AuthMiddleware)
+	group.POST("/users", noopHandler)
+
+	assert.Equals(len(router.Routes()), 2)
+
+	rec := httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equals(rec.Code, http.StatusOK)
+
+	var doc map[string]any
+	assert.Nil(json.Unmarshal(rec.Body.Bytes(), &doc))
+	paths := doc["paths"].(map[string]any)
+	assert.NotNil(paths["/ping"])
+	admin := paths["/admin/users"].(map[string]any)["post"].(map[string]any)
+	assert.NotNil(admin["security"])
+}
@@ -0,0 +1,317 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/log"
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	outboxPubSub = "pubsub"
+	outboxJob    = "job"
+	outboxEvent  = "event"
+
+	outboxPending = "pending"
+	outboxFailed  = "failed"
+)
+
+type outboxMessage struct {
+	f.Entity `bun:"table:outbox_messages"`
+	ID       int64 `bun:",pk,autoincrement"`
+	// Tenant the message was written for, the tenants of the discriminator strategy
+	// sharing the outbox of the default connection. It is not named tenant_id so the
+	// relay is not filtered by the row level security policies.
+	Tenant      string    `bun:",nullzero"`
+	Kind        string    `bun:",notnull"`
+	Topic       string    `bun:",notnull"`
+	Payload     string    `bun:",notnull"`
+	Trace       string    `bun:",nullzero"`
+	Status      string    `bun:",notnull"`
+	Attempts    int       `bun:",notnull"`
+	LastError   string    `bun:",nullzero"`
+	CreatedAt   time.Time `bun:",notnull"`
+	AvailableAt time.Time `bun:",notnull"`
+}
+
+// createOutboxTable creates the outbox table in the schema or database of cnx
+func createOutboxTable(ctx context.Context, cnx connectionImpl) error {
+	if _, err := cnx.db.NewCreateTable().Model((*outboxMessage)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create outbox table for %s: %v", cnx.Id, err)
+	}
+	_, err := cnx.db.NewCreateIndex().
+		Model((*outboxMessage)(nil)).
+		Index("outbox_messages_pending_idx").
+		IfNotExists().
+		Column("status", "available_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox index for %s: %v", cnx.Id, err)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// OUTBOX IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+type OutboxImpl struct {
+	f.Outbox
+}
+
+func NewOutbox() f.Outbox {
+	return &OutboxImpl{}
+}
+
+func (o *OutboxImpl) Publish(ctx context.Context, topic string, message string) error {
+	return o.write(ctx, outboxPubSub, topic, message)
+}
+
+func (o *OutboxImpl) Enqueue(ctx context.Context, jobType f.JobType, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job data: %w", err)
+	}
+	return o.write(ctx, outboxJob, jobType, string(payload))
+}
+
+func (o *OutboxImpl) FireEvent(ctx context.Context, evt string, data map[string]any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	return o.write(ctx, outboxEvent, evt, string(payload))
+}
+
+func (o *OutboxImpl) write(ctx context.Context, kind string, topic string, payload string) error {
+	var cnx f.Connection
+	if value, ok := ctx.Value(f.TenantCnxKey{}).(f.Connection); ok && value != nil {
+		cnx = value
+	} else if value, ok := ctx.Value(f.DefaultCnxKey{}).(f.Connection); ok && value != nil {
+		cnx = value
+	} else {
+		return fmt.Errorf("outbox: no database connection in context")
+	}
+	// the relay continues the trace of the request
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	var trace string
+	if len(carrier) > 0 {
+		data, _ := json.Marshal(carrier)
+		trace = string(data)
+	}
+	now := time.Now()
+	return cnx.Insert(ctx, &outboxMessage{
+		Tenant:      f.CurrentTenant(ctx),
+		Kind:        kind,
+		Topic:       topic,
+		Payload:     payload,
+		Trace:       trace,
+		Status:      outboxPending,
+		CreatedAt:   now,
+		AvailableAt: now,
+	})
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// OUTBOX RELAY
+// ------------------------------------------------------------------------------------------------------------------
+
+type OutboxRelayConfig struct {
+	PubSub   f.PubSubProvider
+	Queue    f.QueueClient
+	Reporter f.ErrorReporter
+	// Interval between two polls of the outbox tables, 1s by default
+	Interval time.Duration
+	// BatchSize is the maximum number of messages relayed per connection and poll, 100 by default
+	BatchSize int
+	// MaxAttempts before a message is marked as failed, 10 by default
+	MaxAttempts int
+}
+
+// OutboxRelay delivers the messages written by the outbox of every tenant connection.
+// Messages are deleted once delivered, a failed delivery is retried with an exponential
+// backoff so a message can be delivered more than once.
+type OutboxRelay struct {
+	ds     *MultiTenantDataSource
+	cfg    OutboxRelayConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+func NewOutboxRelay(ds *MultiTenantDataSource, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	return &OutboxRelay{ds: ds, cfg: cfg}
+}
+
+// Start polls the outbox tables in the background until Stop is called
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return nil
+	}
+	ctx, r.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.RelayAll(ctx)
+			}
+		}
+	}()
+	log.Info("[outbox] relay started")
+	return nil
+}
+
+// Stop waits for the current poll to complete
+func (r *OutboxRelay) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel = nil
+	r.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		log.Info("[outbox] relay stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("outbox relay shutdown: %w", ctx.Err())
+	}
+}
+
+// RelayAll delivers the pending messages of every connection and returns how many were delivered
+func (r *OutboxRelay) RelayAll(ctx context.Context) int {
+	delivered := 0
+	for tenant, cnx := range r.ds.Connections() {
+		impl, ok := cnx.(connectionImpl)
+		if !ok {
+			continue
+		}
+		n, err := r.relay(ctx, tenant, impl)
+		delivered += n
+		if err != nil && ctx.Err() == nil {
+			log.Error("[outbox] failed to relay messages of %s: %v", tenant, err)
+			if r.cfg.Reporter != nil {
+				r.cfg.Reporter.CaptureError(context.WithValue(ctx, f.TenantKey{}, tenant), err)
+			}
+		}
+	}
+	return delivered
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, tenant string, cnx connectionImpl) (int, error) {
+	db, ok := cnx.db.(*bun.DB)
+	if !ok {
+		return 0, nil
+	}
+	delivered := 0
+	err := db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		var messages []outboxMessage
+		q := tx.NewSelect().
+			Model(&messages).
+			Where("status = ?", outboxPending).
+			Where("available_at <= ?", time.Now()).
+			Order("id ASC").
+			Limit(r.cfg.BatchSize)
+		if cnx.dialect == "postgres" || cnx.dialect == "mysql" {
+			// concurrent relays of other instances skip the rows being delivered
+			q = q.For("UPDATE SKIP LOCKED")
+		}
+		if err := q.Scan(ctx); err != nil {
+			return err
+		}
+		for i := range messages {
+			message := &messages[i]
+			if err := r.deliver(ctx, tenant, message); err != nil {
+				message.Attempts++
+				message.LastError = err.Error()
+				message.AvailableAt = time.Now().Add(r.backoff(message.Attempts))
+				if message.Attempts >= r.cfg.MaxAttempts {
+					message.Status = outboxFailed
+					log.Error("[outbox] giving up %s %s of %s after %d attempts: %v", message.Kind, message.Topic, tenant, message.Attempts, err)
+				}
+				if _, err := tx.NewUpdate().Model(message).Column("attempts", "last_error", "available_at", "status").WherePK().Exec(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := tx.NewDelete().Model(message).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			delivered++
+		}
+		return nil
+	})
+	return delivered, err
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, tenant string, message *outboxMessage) (err error) {
+	if message.Trace != "" {
+		var carrier propagation.MapCarrier
+		if json.Unmarshal([]byte(message.Trace), &carrier) == nil {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+		}
+	}
+	if message.Tenant != "" {
+		tenant = message.Tenant
+	}
+	ctx = context.WithValue(ctx, f.TenantKey{}, tenant)
+	defer func() {
+		if value := recover(); value != nil {
+			err = fmt.Errorf("%s %s panicked: %v", message.Kind, message.Topic, value)
+		}
+	}()
+	switch message.Kind {
+	case outboxPubSub:
+		if r.cfg.PubSub == nil {
+			return fmt.Errorf("no pubsub provider to publish %s", message.Topic)
+		}
+		return r.cfg.PubSub.Publish(ctx, message.Topic, message.Payload)
+	case outboxJob:
+		if r.cfg.Queue == nil {
+			return fmt.Errorf("no queue client to enqueue %s", message.Topic)
+		}
+		_, err := r.cfg.Queue.Enqueue(ctx, message.Topic, json.RawMessage(message.Payload))
+		return err
+	case outboxEvent:
+		var data map[string]any
+		if err := json.Unmarshal([]byte(message.Payload), &data); err != nil {
+			return err
+		}
+		f.FireEvent(ctx, message.Topic, data)
+		return nil
+	}
+	return fmt.Errorf("unsupported outbox message kind: %s", message.Kind)
+}
+
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.cfg.Interval << min(attempts-1, 16)
+	return min(delay, 5*time.Minute)
+}
@@ -0,0 +1,166 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+type recordingQueue struct {
+	f.QueueClient
+	mu      sync.Mutex
+	jobs    []string
+	tenants []string
+	err     error
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, jobType string, data any) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return "", q.err
+	}
+	q.jobs = append(q.jobs, jobType)
+	q.tenants = append(q.tenants, f.CurrentTenant(ctx))
+	return "1", nil
+}
+
+func newOutboxDS(t *testing.T) *MultiTenantDataSource {
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: test.TestDatabaseURL()}).UseOutbox()
+	ds.UseTenantProvider(&mockTenantProvider{tenants: []f.Tenant{
+		{ID: "tenant1", Slug: "tenant-one", DatabaseUrl: test.TestDatabaseURL()},
+	}})
+	if err := ds.Init(nil); err != nil {
+		t.Fatalf("failed to init data source: %v", err)
+	}
+	t.Cleanup(func() { _ = ds.Close() })
+	return ds
+}
+
+// inTx runs fn with a transaction of the tenant connection bound to the context, like wrapHandler
+func inTx(t *testing.T, ds *MultiTenantDataSource, tenant string, commit bool, fn func(ctx context.Context)) {
+	tx, err := ds.Connection(tenant).Tx(context.Background())
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	fn(context.WithValue(context.Background(), f.TenantCnxKey{}, tx))
+	if commit {
+		err = tx.Commit()
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatalf("failed to end transaction: %v", err)
+	}
+}
+
+func TestOutbox_RelaysCommittedMessagesOnly(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := newOutboxDS(t)
+	pubsub := NewFakePubSubProvider().(*FakePubSubProvider)
+	queue := &recordingQueue{}
+	relay := NewOutboxRelay(ds, OutboxRelayConfig{PubSub: pubsub, Queue: queue})
+	outbox := NewOutbox()
+
+	inTx(t, ds, "tenant1", false, func(ctx context.Context) {
+		assert.Nil(outbox.Publish(ctx, "invoice_created", `{"id":"1"}`))
+	})
+	assert.Equals(relay.RelayAll(context.Background()), 0)
+
+	inTx(t, ds, "tenant1", true, func(ctx context.Context) {
+		assert.Nil(outbox.Publish(ctx, "invoice_created", `{"id":"2"}`))
+		assert.Nil(outbox.Enqueue(ctx, "send_invoice", map[string]string{"id": "2"}))
+	})
+	inTx(t, ds, "default", true, func(ctx context.Context) {
+		assert.Nil(outbox.Publish(ctx, "tenant_billed", `{"tenant":"tenant1"}`))
+	})
+
+	assert.Equals(relay.RelayAll(context.Background()), 3)
+	assert.Equals(pubsub.Sent("invoice_created"), 1)
+	assert.Equals(pubsub.Sent("tenant_billed"), 1)
+	assert.Equals(queue.jobs, []string{"send_invoice"})
+	// delivered messages are removed
+	assert.Equals(relay.RelayAll(context.Background()), 0)
+
+	assert.NotNil(outbox.Publish(context.Background(), "orphan", "{}"))
+}
+
+func TestOutbox_RetriesFailedDeliveries(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := newOutboxDS(t)
+	queue := &recordingQueue{err: errors.New("redis is down")}
+	relay := NewOutboxRelay(ds, OutboxRelayConfig{Queue: queue, Interval: time.Millisecond, MaxAttempts: 2})
+
+	inTx(t, ds, "tenant1", true, func(ctx context.Context) {
+		assert.Nil(NewOutbox().Enqueue(ctx, "send_invoice", map[string]string{"id": "1"}))
+	})
+	assert.Equals(relay.RelayAll(context.Background()), 0)
+
+	var message outboxMessage
+	cnx := ds.Connection("tenant1")
+	_, err := cnx.FindBy(context.Background(), &message, "topic = ?", "send_invoice")
+	assert.Nil(err)
+	assert.Equals(message.Attempts, 1)
+	assert.Equals(message.Status, outboxPending)
+	assert.Equals(message.LastError, "redis is down")
+
+	queue.mu.Lock()
+	queue.err = nil
+	queue.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equals(relay.RelayAll(context.Background()), 1)
+	assert.Equals(queue.jobs, []string{"send_invoice"})
+}
+
+func TestOutbox_RelaysInTheTenantOfTheMessage(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "shared.db"),
+		Strategy:    f.DiscriminatorStrategy,
+	}).UseOutbox()
+	ds.UseTenantProvider(&mockTenantProvider{tenants: []f.Tenant{{ID: "acme"}, {ID: "demo"}}})
+	assert.Nil(ds.Init(nil))
+	t.Cleanup(func() { _ = ds.Close() })
+	queue := &recordingQueue{}
+	relay := NewOutboxRelay(ds, OutboxRelayConfig{Queue: queue})
+
+	for _, tenant := range []string{"acme", "demo"} {
+		tx, err := ds.Connection(tenant).Tx(context.Background())
+		assert.Nil(err)
+		ctx := context.WithValue(tenantCtx(tenant), f.TenantCnxKey{}, tx)
+		assert.Nil(NewOutbox().Enqueue(ctx, "send_invoice", map[string]string{"tenant": tenant}))
+		assert.Nil(tx.Commit())
+	}
+	// the tenants share the outbox of the default connection
+	assert.Equals(relay.RelayAll(context.Background()), 2)
+	assert.Equals(queue.tenants, []string{"acme", "demo"})
+}
+
+func TestOutboxRelay_StartStop(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := newOutboxDS(t)
+	pubsub := NewFakePubSubProvider()
+	received := make(chan string, 1)
+	pubsub.Subscribe(context.Background(), "invoice_created", func(ctx context.Context, message string) {
+		received <- message
+	})
+	relay := NewOutboxRelay(ds, OutboxRelayConfig{PubSub: pubsub, Interval: 5 * time.Millisecond})
+	assert.Nil(relay.Start(context.Background()))
+
+	inTx(t, ds, "tenant1", true, func(ctx context.Context) {
+		assert.Nil(NewOutbox().Publish(ctx, "invoice_created", `{"id":"1"}`))
+	})
+	select {
+	case message := <-received:
+		assert.Equals(message, `{"id":"1"}`)
+	case <-time.After(time.Second):
+		t.Fatal("message not relayed")
+	}
+	assert.Nil(relay.Stop(context.Background()))
+}
@@ -0,0 +1,273 @@
+package adapters
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// OFFSET PAGINATION
+// ------------------------------------------------------------------------------------------------------------------
+
+func paginateOffset(ctx context.Context, db bun.IDB, models f.Entity, req f.PageRequest, opts ...f.QueryOpts) (f.PageInfo, error) {
+	size, page := req.PageSize(), req.PageNumber()
+	q, err := buildQuery(db.NewSelect(), models, opts...)
+	if err != nil {
+		return f.PageInfo{}, err
+	}
+	total, err := q.Limit(size).Offset((page - 1) * size).ScanAndCount(ctx)
+	if err != nil {
+		return f.PageInfo{}, err
+	}
+	return f.PageInfo{
+		Page:    page,
+		Size:    size,
+		Total:   &total,
+		HasNext: page*size < total,
+		HasPrev: page > 1,
+	}, nil
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// CURSOR PAGINATION
+// ------------------------------------------------------------------------------------------------------------------
+
+type pageCursor struct {
+	// Backward is set for cursors pointing to the previous page
+	Backward bool              `json:"b,omitempty"`
+	Values   []json.RawMessage `json:"v"`
+}
+
+type keysetColumn struct {
+	sort  f.Sort
+	field *schema.Field
+	// nullable columns are ordered and compared with their NULLs sorting last
+	nullable bool
+}
+
+// paginateCursor implements keyset pagination: rows are ordered by the requested sorts
+// followed by the primary key, so the order stays stable on non-unique columns, and the
+// cursor holds the values of these columns for the row the next page starts after.
+// NULLs sort after the other values, last in ascending order and first in descending one.
+func paginateCursor(ctx context.Context, db bun.IDB, models f.Entity, req f.PageRequest, opts ...f.QueryOpts) (f.PageInfo, error) {
+	size := req.PageSize()
+	slice := reflect.ValueOf(models)
+	if slice.Kind() != reflect.Pointer || slice.Elem().Kind() != reflect.Slice {
+		return f.PageInfo{}, fmt.Errorf("cursor pagination expects a pointer to a slice, got %T", models)
+	}
+	slice = slice.Elem()
+	table := db.Dialect().Tables().Get(slice.Type().Elem())
+
+	// the keyset replaces the ordering and limits of opts
+	var sorts []f.Sort
+	filters := make([]f.QueryOpts, 0, len(opts))
+	for _, o := range opts {
+		if o.OrderBy != "" {
+			return f.PageInfo{}, fmt.Errorf("cursor pagination requires typed sorts, got OrderBy %q", o.OrderBy)
+		}
+		sorts = append(sorts, o.Sort...)
+		o.Sort, o.Limit, o.Offset = nil, 0, 0
+		filters = append(filters, o)
+	}
+	keyset, err := keysetColumns(table, sorts)
+	if err != nil {
+		return f.PageInfo{}, err
+	}
+
+	var cursor pageCursor
+	if req.Cursor != "" {
+		if cursor, err = decodeCursor(req.Cursor, keyset); err != nil {
+			return f.PageInfo{}, err
+		}
+	}
+	q, err := buildQuery(db.NewSelect(), models, filters...)
+	if err != nil {
+		return f.PageInfo{}, err
+	}
+	if req.Cursor != "" {
+		after, err := keysetCondition(keyset, cursor)
+		if err != nil {
+			return f.PageInfo{}, err
+		}
+		if q, err = applyFilter(q, after); err != nil {
+			return f.PageInfo{}, err
+		}
+	}
+	for _, column := range keyset {
+		sort := column.sort
+		// a previous page is read backwards then reversed
+		sort.Desc = sort.Desc != cursor.Backward
+		if column.nullable {
+			direction := " ASC"
+			if sort.Desc {
+				direction = " DESC"
+			}
+			q = q.OrderExpr(columnSQL(sort.Column)+" IS NULL"+direction, bun.Ident(sort.Column))
+		}
+		q = applySort(q, []f.Sort{sort})
+	}
+	// one extra row tells whether there is a page after this one
+	if err := q.Limit(size + 1).Scan(ctx); err != nil {
+		return f.PageInfo{}, err
+	}
+
+	more := slice.Len() > size
+	if more {
+		slice.Set(slice.Slice(0, size))
+	}
+	if cursor.Backward {
+		reverse(slice)
+	}
+	info := f.PageInfo{Size: size}
+	if cursor.Backward {
+		info.HasPrev, info.HasNext = more, true
+	} else {
+		info.HasPrev, info.HasNext = req.Cursor != "", more
+	}
+	if slice.Len() > 0 {
+		if info.HasNext {
+			if info.NextCursor, err = encodeCursor(keyset, slice.Index(slice.Len()-1), false); err != nil {
+				return f.PageInfo{}, err
+			}
+		}
+		if info.HasPrev {
+			if info.PrevCursor, err = encodeCursor(keyset, slice.Index(0), true); err != nil {
+				return f.PageInfo{}, err
+			}
+		}
+	}
+	return info, nil
+}
+
+func keysetColumns(table *schema.Table, sorts []f.Sort) ([]keysetColumn, error) {
+	var columns []keysetColumn
+	seen := map[string]bool{}
+	add := func(sort f.Sort) error {
+		if seen[sort.Column] {
+			return nil
+		}
+		field := table.LookupField(sort.Column)
+		if field == nil {
+			return fmt.Errorf("cannot paginate on unknown column %s of %s", sort.Column, table.Name)
+		}
+		seen[sort.Column] = true
+		columns = append(columns, keysetColumn{sort: sort, field: field, nullable: !field.IsPK && !field.NotNull})
+		return nil
+	}
+	for _, sort := range sorts {
+		if err := add(sort); err != nil {
+			return nil, err
+		}
+	}
+	for _, pk := range table.PKs {
+		if err := add(f.Asc(pk.Name)); err != nil {
+			return nil, err
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("cursor pagination on %s requires a primary key or a sort", table.Name)
+	}
+	return columns, nil
+}
+
+// keysetCondition matches the rows after the cursor:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ...
+// with NULL taken as greater than any value of a nullable column.
+func keysetCondition(keyset []keysetColumn, cursor pageCursor) (f.Condition, error) {
+	values := make([]any, len(keyset))
+	nulls := make([]bool, len(keyset))
+	for i, column := range keyset {
+		value := reflect.New(column.field.IndirectType)
+		if err := json.Unmarshal(cursor.Values[i], value.Interface()); err != nil {
+			return nil, errors.BadRequest("invalid cursor")
+		}
+		values[i] = value.Elem().Interface()
+		nulls[i] = column.nullable && isNullValue(column.field, cursor.Values[i], value.Elem())
+	}
+	var branches []f.Condition
+	for i, column := range keyset {
+		var conditions []f.Condition
+		for j := 0; j < i; j++ {
+			if nulls[j] {
+				conditions = append(conditions, f.IsNull(keyset[j].sort.Column))
+			} else {
+				conditions = append(conditions, f.Eq(keyset[j].sort.Column, values[j]))
+			}
+		}
+		name := column.sort.Column
+		switch {
+		case column.sort.Desc != cursor.Backward && nulls[i]:
+			conditions = append(conditions, f.NotNull(name))
+		case column.sort.Desc != cursor.Backward:
+			conditions = append(conditions, f.Lt(name, values[i]))
+		case nulls[i]:
+			// nothing sorts after NULL
+			conditions = append(conditions, f.In(name))
+		case column.nullable:
+			conditions = append(conditions, f.Or(f.Gt(name, values[i]), f.IsNull(name)))
+		default:
+			conditions = append(conditions, f.Gt(name, values[i]))
+		}
+		branches = append(branches, f.And(conditions...))
+	}
+	return f.Or(branches...), nil
+}
+
+// isNullValue tells whether the cursor value of field stands for NULL in the database
+func isNullValue(field *schema.Field, raw json.RawMessage, value reflect.Value) bool {
+	if string(raw) == "null" {
+		return true
+	}
+	if field.NullZero && value.IsZero() {
+		return true
+	}
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		v, err := valuer.Value()
+		return err == nil && v == nil
+	}
+	return false
+}
+
+func encodeCursor(keyset []keysetColumn, item reflect.Value, backward bool) (string, error) {
+	cursor := pageCursor{Backward: backward}
+	for _, column := range keyset {
+		value, err := json.Marshal(column.field.Value(reflect.Indirect(item)).Interface())
+		if err != nil {
+			return "", fmt.Errorf("failed to encode cursor: %v", err)
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string, keyset []keysetColumn) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, errors.BadRequest("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.Values) != len(keyset) {
+		return cursor, errors.BadRequest("invalid cursor")
+	}
+	return cursor, nil
+}
+
+func reverse(slice reflect.Value) {
+	swap := reflect.Swapper(slice.Interface())
+	for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
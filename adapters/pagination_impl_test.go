@@ -0,0 +1,169 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+func seedPagedUsers(t *testing.T, count int) context.Context {
+	cnx := setupTestTable(t, test.TestDatabaseURL())
+	var users []TestUser
+	for i := 1; i <= count; i++ {
+		// ages repeat so that the cursor has to break ties on the primary key
+		users = append(users, TestUser{Name: fmt.Sprintf("user-%02d", i), Email: fmt.Sprintf("user%d@example.com", i), Age: 20 + i%3})
+	}
+	if err := cnx.InsertBatch(context.Background(), &users); err != nil {
+		t.Fatalf("failed to seed users: %v", err)
+	}
+	return context.WithValue(context.Background(), f.DefaultCnxKey{}, cnx)
+}
+
+func TestPaginate_Offset(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := seedPagedUsers(t, 7)
+	repo := f.NewRepository[TestUser]()
+
+	page, err := repo.Paginate(ctx, f.PageRequest{Page: 2, Size: 3}, f.QueryOpts{Sort: []f.Sort{f.Asc("name")}})
+	assert.Nil(err)
+	assert.Equals(names(page.Items), []string{"user-04", "user-05", "user-06"})
+	assert.Equals(*page.Total, 7)
+	assert.Equals(page.Page, 2)
+	assert.True(page.HasNext)
+	assert.True(page.HasPrev)
+
+	page, err = repo.Paginate(ctx, f.PageRequest{Page: 3, Size: 3}, f.QueryOpts{Filter: f.Gte("age", 21)})
+	assert.Nil(err)
+	assert.Equals(len(page.Items), 0)
+	assert.Equals(*page.Total, 5)
+	assert.False(page.HasNext)
+}
+
+func TestPaginate_Cursor(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := seedPagedUsers(t, 8)
+	repo := f.NewRepository[TestUser]()
+	opts := f.QueryOpts{Sort: []f.Sort{f.Desc("age")}}
+
+	// walk forward through the pages
+	var seen []string
+	req := f.PageRequest{Mode: f.CursorPaging, Size: 3}
+	var pages []*f.Page[TestUser]
+	for {
+		page, err := repo.Paginate(ctx, req, opts)
+		assert.Nil(err)
+		pages = append(pages, page)
+		seen = append(seen, names(page.Items)...)
+		if !page.HasNext {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+	assert.Equals(len(pages), 3)
+	// age desc then id asc
+	assert.Equals(seen, []string{"user-02", "user-05", "user-08", "user-01", "user-04", "user-07", "user-03", "user-06"})
+	assert.False(pages[0].HasPrev)
+	assert.Equals(pages[0].PrevCursor, "")
+
+	// and back from the last page
+	page, err := repo.Paginate(ctx, f.PageRequest{Mode: f.CursorPaging, Size: 3, Cursor: pages[2].PrevCursor}, opts)
+	assert.Nil(err)
+	assert.Equals(names(page.Items), names(pages[1].Items))
+	assert.True(page.HasPrev)
+	assert.True(page.HasNext)
+
+	page, err = repo.Paginate(ctx, f.PageRequest{Mode: f.CursorPaging, Size: 3, Cursor: page.PrevCursor}, opts)
+	assert.Nil(err)
+	assert.Equals(names(page.Items), names(pages[0].Items))
+	assert.False(page.HasPrev)
+
+	_, err = repo.Paginate(ctx, f.PageRequest{Mode: f.CursorPaging, Cursor: "garbage"}, opts)
+	assert.NotNil(err)
+}
+
+type TestTask struct {
+	f.Entity `bun:"table:test_tasks"`
+	ID       int64 `bun:",pk,autoincrement"`
+	Title    string
+	Rank     *int64
+}
+
+func TestPaginate_CursorNullableColumn(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, cnx f.Connection) {
+		assert := test.NewAssertions(t)
+		ctx := context.WithValue(context.Background(), f.DefaultCnxKey{}, cnx)
+		db := cnx.(connectionImpl).db
+		_, err := db.NewDropTable().Model((*TestTask)(nil)).IfExists().Exec(ctx)
+		assert.Nil(err)
+		_, err = db.NewCreateTable().Model((*TestTask)(nil)).Exec(ctx)
+		assert.Nil(err)
+		rank := func(value int64) *int64 { return &value }
+		tasks := []TestTask{
+			{Title: "a", Rank: rank(1)}, {Title: "b"}, {Title: "c", Rank: rank(2)},
+			{Title: "d"}, {Title: "e", Rank: rank(1)}, {Title: "f", Rank: rank(3)},
+		}
+		assert.Nil(cnx.InsertBatch(ctx, &tasks))
+		repo := f.NewRepository[TestTask]()
+
+		walk := func(opts f.QueryOpts) ([]string, *f.Page[TestTask]) {
+			var seen []string
+			var last *f.Page[TestTask]
+			req := f.PageRequest{Mode: f.CursorPaging, Size: 2}
+			for {
+				page, err := repo.Paginate(ctx, req, opts)
+				assert.Nil(err)
+				last = page
+				for _, task := range page.Items {
+					seen = append(seen, task.Title)
+				}
+				if !page.HasNext {
+					return seen, last
+				}
+				req.Cursor = page.NextCursor
+			}
+		}
+
+		// NULLs come last in ascending order and first in descending one
+		seen, last := walk(f.QueryOpts{Sort: []f.Sort{f.Asc("rank")}})
+		assert.Equals(seen, []string{"a", "e", "c", "f", "b", "d"})
+		page, err := repo.Paginate(ctx, f.PageRequest{Mode: f.CursorPaging, Size: 2, Cursor: last.PrevCursor}, f.QueryOpts{Sort: []f.Sort{f.Asc("rank")}})
+		assert.Nil(err)
+		assert.Equals(page.Items[0].Title, "c")
+		assert.Equals(page.Items[1].Title, "f")
+
+		seen, _ = walk(f.QueryOpts{Sort: []f.Sort{f.Desc("rank")}})
+		assert.Equals(seen, []string{"b", "d", "f", "c", "a", "e"})
+	})
+}
+
+func TestPaginate_HttpHelpers(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := NewEchoRouter(EchoRouterConfig{Env: "test"})
+	router.Init()
+	router.GET("/users", func(c f.HttpContext) error {
+		req, err := f.ParsePageRequest(c, f.OffsetPaging)
+		if err != nil {
+			return err
+		}
+		total := 45
+		info := f.PageInfo{Page: req.PageNumber(), Size: req.PageSize(), Total: &total, HasPrev: true, HasNext: true}
+		f.SetPageLinks(c, info)
+		return c.JSON(http.StatusOK, f.Page[string]{Items: []string{}, PageInfo: info})
+	})
+
+	rec := serve(router, http.MethodGet, "/users?page=2&size=10&sort=name", "")
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.Equals(rec.Header().Get("Link"), `</users?page=1&size=10&sort=name>; rel="first", `+
+		`</users?page=1&size=10&sort=name>; rel="prev", `+
+		`</users?page=3&size=10&sort=name>; rel="next", `+
+		`</users?page=5&size=10&sort=name>; rel="last"`)
+	assert.MatchJson(rec.Body.String(), `{"items":[],"page":2,"size":10,"total":45,"hasNext":true,"hasPrev":true}`)
+
+	rec = serve(router, http.MethodGet, "/users?page=abc", "")
+	assert.Equals(rec.Code, http.StatusBadRequest)
+}
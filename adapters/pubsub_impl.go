@@ -42,6 +42,7 @@ func MustNewPubSubProvider(provider string) f.PubSubProvider {
 
 type RedisPubSubProvider struct {
 	f.PubSubProvider
+	instrumentation
 	client *redis.Client
 }
 
@@ -70,12 +71,19 @@ func (p *RedisPubSubProvider) Init() error {
 	return nil
 }
 
-func (p *RedisPubSubProvider) Publish(ctx context.Context, topic string, message string) error {
-	err := p.client.Publish(ctx, topic, message).Err()
+func (p *RedisPubSubProvider) Close() error {
+	return p.client.Close()
+}
+
+func (p *RedisPubSubProvider) Publish(ctx context.Context, topic string, message string) (err error) {
+	ctx, span, message := publishSpan(ctx, topic, message)
+	defer func() { endSpan(span, err) }()
+	err = p.client.Publish(ctx, topic, message).Err()
 	if err != nil {
 		log.Error("[redis]failed to publish message: %v", err)
 		return err
 	}
+	p.metrics().pubsubPublished.Inc(topic)
 	log.Info("[redis]message published to topic: %s", topic)
 	return nil
 }
@@ -96,7 +104,8 @@ func (p *RedisPubSubProvider) Subscribe(ctx context.Context, topic string, handl
 				continue
 			}
 			log.Debug("[redis] event received: %s", msg.Payload)
-			go handler(ctx, msg.Payload)
+			p.metrics().pubsubReceived.Inc(topic)
+			go receiveMessage(ctx, topic, msg.Payload, handler)
 		}
 	}()
 }
@@ -111,6 +120,7 @@ func (p *RedisPubSubProvider) Ping() error {
 
 type FakePubSubProvider struct {
 	f.PubSubProvider
+	instrumentation
 	sent        map[string]int
 	received    map[string]int
 	subscribers map[string][]func(ctx context.Context, message string)
@@ -133,11 +143,15 @@ func (p *FakePubSubProvider) Init() error {
 }
 
 func (p *FakePubSubProvider) Publish(ctx context.Context, topic string, message string) error {
+	ctx, span, message := publishSpan(ctx, topic, message)
+	defer span.End()
 	p.sent[topic]++
+	p.metrics().pubsubPublished.Inc(topic)
 	handlers := p.subscribers[topic]
 	for _, handler := range handlers {
 		p.received[topic]++
-		go handler(ctx, message)
+		p.metrics().pubsubReceived.Inc(topic)
+		go receiveMessage(ctx, topic, message, handler)
 	}
 	return nil
 }
@@ -0,0 +1,148 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/uptrace/bun"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// QUERY SPEC COMPILER
+// ------------------------------------------------------------------------------------------------------------------
+
+// applyFilter adds cond to the WHERE clause of q, the generated SQL is the same for
+// postgres and sqlite.
+func applyFilter(q *bun.SelectQuery, cond f.Condition) (*bun.SelectQuery, error) {
+	if cond == nil {
+		return q, nil
+	}
+	if err := validateCondition(cond); err != nil {
+		return q, err
+	}
+	return where(q, cond, " AND "), nil
+}
+
+func applySort(q *bun.SelectQuery, sorts []f.Sort) *bun.SelectQuery {
+	for _, sort := range sorts {
+		if sort.Desc {
+			q = q.OrderExpr(columnSQL(sort.Column)+" DESC", bun.Ident(sort.Column))
+		} else {
+			q = q.OrderExpr(columnSQL(sort.Column)+" ASC", bun.Ident(sort.Column))
+		}
+	}
+	return q
+}
+
+// columnSQL is the placeholder of column, qualified with the alias of the table of the
+// query so it stays unambiguous with joins. Columns qualified by the caller are kept.
+func columnSQL(column string) string {
+	if strings.Contains(column, ".") {
+		return "?"
+	}
+	return "?TableAlias.?"
+}
+
+func where(q *bun.SelectQuery, cond f.Condition, sep string) *bun.SelectQuery {
+	switch c := cond.(type) {
+	case f.Group:
+		if len(c.Conditions) == 0 {
+			return q
+		}
+		childSep := " AND "
+		if c.Or {
+			childSep = " OR "
+		}
+		return q.WhereGroup(sep, func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, child := range c.Conditions {
+				q = where(q, child, childSep)
+			}
+			return q
+		})
+	case f.Filter:
+		query, args := filterSQL(c)
+		if sep == " OR " {
+			return q.WhereOr(query, args...)
+		}
+		return q.Where(query, args...)
+	}
+	return q
+}
+
+func filterSQL(c f.Filter) (string, []any) {
+	column := bun.Ident(c.Column)
+	col := columnSQL(c.Column)
+	switch c.Op {
+	case f.OpEq:
+		return col + " = ?", []any{column, c.Values[0]}
+	case f.OpNe:
+		return col + " <> ?", []any{column, c.Values[0]}
+	case f.OpGt:
+		return col + " > ?", []any{column, c.Values[0]}
+	case f.OpGte:
+		return col + " >= ?", []any{column, c.Values[0]}
+	case f.OpLt:
+		return col + " < ?", []any{column, c.Values[0]}
+	case f.OpLte:
+		return col + " <= ?", []any{column, c.Values[0]}
+	case f.OpIn:
+		if len(c.Values) == 0 {
+			return "1 = 0", nil
+		}
+		return col + " IN (?)", []any{column, bun.In(c.Values)}
+	case f.OpLike:
+		return col + " LIKE ?", []any{column, c.Values[0]}
+	case f.OpILike:
+		// ILIKE is postgres only
+		return "LOWER(" + col + ") LIKE LOWER(?)", []any{column, c.Values[0]}
+	case f.OpBetween:
+		from, to := c.Values[0], c.Values[1]
+		switch {
+		case from != nil && to != nil:
+			return col + " BETWEEN ? AND ?", []any{column, from, to}
+		case from != nil:
+			return col + " >= ?", []any{column, from}
+		case to != nil:
+			return col + " <= ?", []any{column, to}
+		}
+		return "1 = 1", nil
+	case f.OpNull:
+		return col + " IS NULL", []any{column}
+	default:
+		return col + " IS NOT NULL", []any{column}
+	}
+}
+
+func validateCondition(cond f.Condition) error {
+	switch c := cond.(type) {
+	case f.Group:
+		for _, child := range c.Conditions {
+			if err := validateCondition(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case f.Filter:
+		if c.Column == "" {
+			return fmt.Errorf("filter column is required")
+		}
+		expected := 1
+		switch c.Op {
+		case f.OpNull, f.OpNotNull:
+			expected = 0
+		case f.OpBetween:
+			expected = 2
+		case f.OpIn:
+			return nil
+		case f.OpEq, f.OpNe, f.OpGt, f.OpGte, f.OpLt, f.OpLte, f.OpLike, f.OpILike:
+		default:
+			return fmt.Errorf("unsupported filter operator: %s", c.Op)
+		}
+		if len(c.Values) != expected {
+			return fmt.Errorf("filter %s on %s expects %d value(s)", c.Op, c.Column, expected)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported condition: %T", cond)
+}
@@ -0,0 +1,109 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+func seedUsers(t *testing.T) f.Connection {
+	cnx := setupTestTable(t, test.TestDatabaseURL())
+	users := []TestUser{
+		{Name: "John Doe", Email: "john@example.com", Age: 30},
+		{Name: "Jane Doe", Email: "jane@example.com", Age: 25},
+		{Name: "Bob Martin", Email: "bob@example.com", Age: 52},
+		{Name: "Alice Smith", Email: "alice@example.com", Age: 17},
+	}
+	if err := cnx.InsertBatch(context.Background(), &users); err != nil {
+		t.Fatalf("failed to seed users: %v", err)
+	}
+	return cnx
+}
+
+func names(users []TestUser) []string {
+	var result []string
+	for _, user := range users {
+		result = append(result, user.Name)
+	}
+	return result
+}
+
+func TestQuery_Filters(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	cnx := seedUsers(t)
+
+	for _, tc := range []struct {
+		filter   f.Condition
+		expected []string
+	}{
+		{f.Eq("name", "Jane Doe"), []string{"Jane Doe"}},
+		{f.Ne("age", 30), []string{"Alice Smith", "Bob Martin", "Jane Doe"}},
+		{f.In("age", 17, 52), []string{"Alice Smith", "Bob Martin"}},
+		{f.In("age"), nil},
+		{f.Like("name", "%Doe"), []string{"Jane Doe", "John Doe"}},
+		{f.ILike("name", "%DOE"), []string{"Jane Doe", "John Doe"}},
+		{f.Between("age", 18, 30), []string{"Jane Doe", "John Doe"}},
+		{f.Between("age", nil, 18), []string{"Alice Smith"}},
+		{f.IsNull("email"), nil},
+		{f.And(f.NotNull("email"), f.Or(f.Gt("age", 50), f.Lt("age", 26))), []string{"Alice Smith", "Bob Martin", "Jane Doe"}},
+		{f.Or(f.Eq("name", "Bob Martin"), f.And(f.Like("name", "J%"), f.Gte("age", 30))), []string{"Bob Martin", "John Doe"}},
+	} {
+		var users []TestUser
+		_, err := cnx.Query(ctx, &users, f.QueryOpts{Filter: tc.filter, Sort: []f.Sort{f.Asc("name")}})
+		assert.Nil(err)
+		assert.Equals(names(users), tc.expected)
+	}
+}
+
+func TestQuery_SchemaFromParams(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	cnx := seedUsers(t)
+
+	schema := f.QuerySchema{Filterable: []string{"name", "age"}, Sortable: []string{"age"}}
+	opts, err := schema.Parse("or(name:like:J%,age:gte:50)", "-age")
+	assert.Nil(err)
+
+	var users []TestUser
+	_, err = cnx.Query(ctx, &users, opts)
+	assert.Nil(err)
+	assert.Equals(names(users), []string{"Bob Martin", "John Doe", "Jane Doe"})
+
+	// injection attempts are bound as values
+	opts, err = schema.Parse("name:eq:x' OR '1'='1", "")
+	assert.Nil(err)
+	users = nil
+	_, err = cnx.Query(ctx, &users, opts)
+	assert.Nil(err)
+	assert.Equals(len(users), 0)
+}
+
+func TestQuery_InvalidFilter(t *testing.T) {
+	assert := test.NewAssertions(t)
+	cnx := seedUsers(t)
+
+	var users []TestUser
+	_, err := cnx.Query(context.Background(), &users, f.QueryOpts{Filter: f.Filter{Column: "age", Op: "regex"}})
+	assert.NotNil(err)
+}
+
+func TestQuery_FiltersWithJoins(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	cnx := seedUsers(t)
+
+	// the joined table has the same columns, filters and sorts apply to the queried one
+	// unless qualified
+	join := "JOIN test_users AS older ON older.age > ?TableAlias.age"
+	var users []TestUser
+	_, err := cnx.Query(ctx, &users, f.QueryOpts{
+		Joins:  []string{join},
+		Filter: f.And(f.Like("name", "J%"), f.Eq("older.name", "Bob Martin")),
+		Sort:   []f.Sort{f.Desc("age")},
+	})
+	assert.Nil(err)
+	assert.Equals(names(users), []string{"John Doe", "Jane Doe"})
+}
@@ -0,0 +1,205 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/h"
+	"github.com/soffa-projects/foundation-go/log"
+	"github.com/uptrace/bun"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// READ REPLICAS
+// ------------------------------------------------------------------------------------------------------------------
+
+const replicaCheckInterval = 10 * time.Second
+
+type replica struct {
+	name    string
+	db      *bun.DB
+	dialect string
+	// schema is the postgres search path of the connections of db, checked with its lag
+	schema  string
+	healthy atomic.Bool
+	mu      sync.Mutex
+	lag     time.Duration
+	err     error
+}
+
+// replicaSet spreads the reads of a connection over its healthy replicas
+type replicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+}
+
+// openReplicas opens the pools of the replicas of cnx, a replica failing its first check,
+// a postgres replica missing the schema of cnx included, is set aside until a later check
+// succeeds.
+func openReplicas(ctx context.Context, cnx connectionImpl, urls []string, maxLag time.Duration) (*replicaSet, error) {
+	set := &replicaSet{maxLag: maxLag}
+	for i, replicaUrl := range urls {
+		replicaUrl, schema, err := splitSchema(replicaUrl)
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		db, dialect, err := openDB(replicaUrl, schema)
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		db.AddQueryHook(queryHook{tenant: cnx.Id, dialect: dialect, metrics: cnx.metrics})
+		set.replicas = append(set.replicas, &replica{
+			name:    fmt.Sprintf("%s/replica-%d", cnx.Id, i+1),
+			db:      db,
+			dialect: dialect,
+			schema:  schema,
+		})
+	}
+	set.check(ctx)
+	return set, nil
+}
+
+// pick returns the next healthy replica, nil when there is none
+func (s *replicaSet) pick() bun.IDB {
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := range n {
+		if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+// check refreshes the health and lag of every replica
+func (s *replicaSet) check(ctx context.Context) {
+	for _, r := range s.replicas {
+		lag, err := r.measureLag(ctx)
+		if err == nil && s.maxLag > 0 && lag > s.maxLag {
+			err = fmt.Errorf("lagging %s behind", lag.Round(time.Millisecond))
+		}
+		r.mu.Lock()
+		r.lag, r.err = lag, err
+		r.mu.Unlock()
+		if healthy := err == nil; r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Info("replica %s is healthy", r.name)
+			} else {
+				log.Warn("replica %s set aside: %v", r.name, err)
+			}
+		}
+	}
+}
+
+func (r *replica) measureLag(ctx context.Context) (time.Duration, error) {
+	if r.dialect != "postgres" {
+		_, err := r.db.NewRaw("SELECT 1").Exec(ctx)
+		return 0, err
+	}
+	var seconds float64
+	var schema sql.NullString
+	// replay timestamp is null on a primary, current schema when the search path is missing
+	err := r.db.NewRaw("SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0), current_schema()").
+		Scan(ctx, &seconds, &schema)
+	if err == nil && r.schema != "" && schema.String != r.schema {
+		err = fmt.Errorf("schema %s not found", r.schema)
+	}
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+func (r *replica) status() (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lag, r.err
+}
+
+func (s *replicaSet) Close() error {
+	var errs []error
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", r.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reader returns the database of the reads outside transactions: a healthy replica
+// unless ctx is pinned to the primary.
+func (t connectionImpl) reader(ctx context.Context) bun.IDB {
+	if t.replicas == nil || t.transaction || f.PinnedToPrimary(ctx) {
+		return t.db
+	}
+	if db := t.replicas.pick(); db != nil {
+		return db
+	}
+	return t.db
+}
+
+// checkReplicas refreshes the health of the replicas of every connection
+func (ds *MultiTenantDataSource) checkReplicas(ctx context.Context) {
+	for _, cnx := range ds.Connections() {
+		if impl, ok := cnx.(connectionImpl); ok && impl.replicas != nil {
+			impl.replicas.check(ctx)
+		}
+	}
+}
+
+// CheckReplicas reports the lag of the replicas, the unavailable or lagging ones as a
+// degraded health component since their reads fall back to the primary.
+func (ds *MultiTenantDataSource) CheckReplicas(ctx context.Context) error {
+	var unavailable, lags []string
+	for _, cnx := range ds.Connections() {
+		impl, ok := cnx.(connectionImpl)
+		if !ok || impl.replicas == nil {
+			continue
+		}
+		impl.replicas.check(ctx)
+		for _, r := range impl.replicas.replicas {
+			lag, err := r.status()
+			if err != nil {
+				unavailable = append(unavailable, fmt.Sprintf("%s: %v", r.name, err))
+			} else {
+				lags = append(lags, fmt.Sprintf("%s: lag %s", r.name, lag.Round(time.Millisecond)))
+			}
+		}
+	}
+	sort.Strings(unavailable)
+	sort.Strings(lags)
+	if len(unavailable) > 0 {
+		return f.Degraded(fmt.Errorf("%d replica(s) unavailable: %s", len(unavailable), strings.Join(append(unavailable, lags...), "; ")))
+	}
+	if len(lags) > 0 {
+		return f.Healthy(strings.Join(lags, "; "))
+	}
+	return nil
+}
+
+// tenantReplicas returns the replica urls of tenant, the ones of the default database
+// with the schema strategy
+func (ds *MultiTenantDataSource) tenantReplicas(tenant f.Tenant) []string {
+	switch ds.cfg.Strategy {
+	case f.SchemaStrategy:
+		if hasSchemas(ds.cfg.DatabaseUrl) {
+			replicas := make([]string, 0, len(ds.cfg.ReplicaUrls))
+			for _, replicaUrl := range ds.cfg.ReplicaUrls {
+				replicas = append(replicas, h.AppendParamToUrl(replicaUrl, "schema", tenant.ID))
+			}
+			return replicas
+		}
+	case f.DiscriminatorStrategy:
+		// the tenants share the replicas of the default connection
+		return nil
+	}
+	return tenant.ReplicaUrls
+}
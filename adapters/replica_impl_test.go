@@ -0,0 +1,127 @@
+package adapters
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/h"
+	"github.com/soffa-projects/foundation-go/test"
+	"github.com/uptrace/bun"
+)
+
+var accountsFeature = f.Feature{
+	Name: "accounts",
+	FS: fstest.MapFS{
+		"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+	},
+}
+
+// newReplicatedDS returns a data source reading from a distinct database standing for a replica
+func newReplicatedDS(t *testing.T) *MultiTenantDataSource {
+	dir := t.TempDir()
+	replicaUrl := "sqlite://" + filepath.Join(dir, "replica.db")
+	if err := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: replicaUrl}).Init([]f.Feature{accountsFeature}); err != nil {
+		t.Fatal(err)
+	}
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: "sqlite://" + filepath.Join(dir, "primary.db"),
+		ReplicaUrls: []string{replicaUrl},
+	})
+	if err := ds.Init([]f.Feature{accountsFeature}); err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func TestReplicas_RoutesReads(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	ds := newReplicatedDS(t)
+	cnx := ds.DefaultConnection()
+
+	assert.Nil(cnx.Insert(ctx, &account{ID: "1"}))
+	count, err := cnx.Count(ctx, &account{})
+	assert.Nil(err)
+	assert.Equals(count, 0)
+
+	count, err = cnx.Count(f.PinPrimary(ctx), &account{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+
+	tx, err := cnx.Tx(ctx)
+	assert.Nil(err)
+	defer tx.Rollback()
+	exists, err := tx.ExistsBy(ctx, &account{}, "id = ?", "1")
+	assert.Nil(err)
+	assert.True(exists)
+}
+
+func TestReplicas_Health(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ctx := context.Background()
+	ds := newReplicatedDS(t)
+	cnx := ds.DefaultConnection()
+	assert.Nil(cnx.Insert(ctx, &account{ID: "1"}))
+
+	health := f.NewHealthChecks(0)
+	health.Add("replicas", ds.CheckReplicas)
+	res := health.Run(ctx, "test")
+	assert.Equals(res.Components["replicas"].Status, "UP")
+	assert.Equals(res.Components["replicas"].Message, "default/replica-1: lag 0s")
+
+	// reads fall back to the primary once the replica is set aside
+	assert.Nil(cnx.(connectionImpl).replicas.replicas[0].db.Close())
+	res = health.Run(ctx, "test")
+	assert.Equals(res.Status, "UP")
+	assert.Equals(res.Components["replicas"].Status, "DEGRADED")
+	assert.True(strings.HasPrefix(res.Components["replicas"].Message, "1 replica(s) unavailable: default/replica-1:"))
+	count, err := cnx.Count(ctx, &account{})
+	assert.Nil(err)
+	assert.Equals(count, 1)
+}
+
+func TestReplicas_UnsupportedUrl(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: test.TestDatabaseURL(),
+		ReplicaUrls: []string{"invalid://replica"},
+	})
+	assert.NotNil(ds.Init([]f.Feature{}))
+}
+
+func TestReplicas_SetsSchemaAside_Postgres(t *testing.T) {
+	databaseUrl := os.Getenv("TEST_POSTGRES_URL")
+	if databaseUrl == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+	assert := test.NewAssertions(t)
+	schema := "replicas_" + strings.ToLower(h.RandomString(8))
+	ds := NewMultiTenantDS(f.DataSourceConfig{
+		DatabaseUrl: h.AppendParamToUrl(databaseUrl, "schema", schema),
+		ReplicaUrls: []string{
+			h.AppendParamToUrl(databaseUrl, "schema", schema),
+			h.AppendParamToUrl(databaseUrl, "schema", schema+"_missing"),
+		},
+	})
+	assert.Nil(ds.Init([]f.Feature{accountsFeature}))
+	cnx := ds.DefaultConnection().(connectionImpl)
+	t.Cleanup(func() {
+		_, _ = cnx.db.ExecContext(context.Background(), "DROP SCHEMA ? CASCADE", bun.Ident(schema))
+		_ = ds.Close()
+	})
+
+	// every pooled connection of a replica reads its schema
+	assert.True(cnx.replicas.replicas[0].healthy.Load())
+	assert.False(cnx.replicas.replicas[1].healthy.Load())
+	_, err := cnx.replicas.replicas[1].status()
+	assert.Equals(err.Error(), "schema "+schema+"_missing not found")
+	count, err := cnx.Count(context.Background(), &account{})
+	assert.Nil(err)
+	assert.Equals(count, 0)
+}
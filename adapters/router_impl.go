@@ -1,17 +1,21 @@
 package adapters
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	stderrors "errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/a-h/templ"
-	"github.com/getsentry/sentry-go"
-	sentryecho "github.com/getsentry/sentry-go/echo"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo-contrib/session"
 	echoSwagger "github.com/swaggo/echo-swagger"
@@ -25,6 +29,12 @@ import (
 	"github.com/soffa-projects/foundation-go/errors"
 	"github.com/soffa-projects/foundation-go/h"
 	"github.com/soffa-projects/foundation-go/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const _authKey = "auth"
@@ -32,6 +42,14 @@ const _authTokenKey = "authToken"
 const _tenantIdKey = "tenantId"
 const _idemPotencyKey = "idempotencyKey"
 
+const problemContentType = "application/problem+json"
+
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	metricsPath   = "/metrics"
+)
+
 type EchoRouterConfig struct {
 	Debug          bool
 	PublicFS       fs.FS
@@ -43,11 +61,48 @@ type EchoRouterConfig struct {
 	TenantProvider f.TenantProvider
 	AuthProvider   f.AuthProvider
 	DataSource     f.DataSource
+	AppInfo        f.AppInfo
+	ErrorReporter  f.ErrorReporter
+	Container      *f.Container
+	HealthChecks   *f.HealthChecks
+	// Metrics is served on /metrics when set
+	Metrics f.Metrics
+	// LegacyErrorFormat renders errors as {requestId,timestamp,uri,error,success}
+	// instead of application/problem+json
+	LegacyErrorFormat bool
+	// TxRetries is the default of f.TxOptions.Retries
+	TxRetries int
 }
 
 func NewEchoRouter(cfg EchoRouterConfig) f.Router {
 	e := echo.New()
 
+	reporter := cfg.ErrorReporter
+	if reporter == nil && cfg.SentryDSN != "" {
+		reporter = NewSentryErrorReporter(cfg.SentryDSN, cfg.Env)
+		log.Info("[echo] sentry error reporter initialized")
+	}
+
+	router := &routerImpl{
+		internal:       e,
+		tokenProvider:  cfg.TokenProvider,
+		authProvider:   cfg.AuthProvider,
+		tenantProvider: cfg.TenantProvider,
+		ds:             cfg.DataSource,
+		info:           cfg.AppInfo,
+		routes:         &routeRegistry{},
+		reporter:       reporter,
+		legacyErrors:   cfg.LegacyErrorFormat,
+		txRetries:      cfg.TxRetries,
+	}
+
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+		_ = router.formatError(newHttpContext(c), err, 0)
+	}
+
 	// Only use pretty logger in non-test environments for cleaner test output
 	if cfg.Env != "test" {
 		e.Use(prettylogger.Logger)
@@ -66,6 +121,17 @@ func NewEchoRouter(cfg EchoRouterConfig) f.Router {
 	e.Use(middleware.Recover())
 	e.Use(middleware.RemoveTrailingSlash())
 	e.Use(middleware.RequestID())
+	e.Use(instrumentRequests(instrumentsOf(cfg.Metrics)))
+	if cfg.Container != nil {
+		container := cfg.Container
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				req := c.Request()
+				c.SetRequest(req.WithContext(f.WithContainer(req.Context(), container)))
+				return next(c)
+			}
+		})
+	}
 
 	if cfg.PublicFS != nil {
 
@@ -83,7 +149,7 @@ func NewEchoRouter(cfg EchoRouterConfig) f.Router {
 		assets.StaticFS("/", cfg.PublicFS)
 
 	}
-	e.GET("/swagger/*", echoSwagger.WrapHandler)
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandler(echoSwagger.URL(openAPIPath)))
 
 	if cfg.SessionSecret != "" {
 		e.Logger.Info("session secret found, enabling session middleware")
@@ -97,27 +163,36 @@ func NewEchoRouter(cfg EchoRouterConfig) f.Router {
 			AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
 		}))
 	}
-	if cfg.SentryDSN != "" {
-		if err := sentry.Init(sentry.ClientOptions{
-			Dsn:         cfg.SentryDSN,
-			Environment: cfg.Env,
-		}); err != nil {
-			log.Error("Sentry initialization failed: %v\n", err)
-		} else {
-			e.Use(sentryecho.New(sentryecho.Options{}))
-			log.Info("[echo] sentry middle initialized successfully")
-		}
-	}
 
 	// Tenant middleware
 
-	return &routerImpl{
-		internal:       e,
-		tokenProvider:  cfg.TokenProvider,
-		authProvider:   cfg.AuthProvider,
-		tenantProvider: cfg.TenantProvider,
-		ds:             cfg.DataSource,
+	e.GET(openAPIPath, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, newOpenAPIDocument(router.info, router.Routes(), router.legacyErrors))
+	})
+
+	if cfg.Metrics != nil {
+		e.GET(metricsPath, echo.WrapHandler(cfg.Metrics.Handler()))
 	}
+
+	// Kubernetes probes: the app stays alive while draining but stops being ready
+	e.GET(livenessPath, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, f.HealthCheckResponse{Whoami: router.info.Name, Status: "UP"})
+	})
+	e.GET(readinessPath, func(c echo.Context) error {
+		if !router.ready.Load() {
+			return c.JSON(http.StatusServiceUnavailable, f.HealthCheckResponse{Whoami: router.info.Name, Status: "DOWN"})
+		}
+		if cfg.HealthChecks == nil {
+			return c.JSON(http.StatusOK, f.HealthCheckResponse{Whoami: router.info.Name, Status: "UP"})
+		}
+		health := cfg.HealthChecks.Run(c.Request().Context(), router.info.Name)
+		if health.Status != "UP" {
+			return c.JSON(http.StatusServiceUnavailable, health)
+		}
+		return c.JSON(http.StatusOK, health)
+	})
+
+	return router
 }
 
 // ------------------------------------------------------------------------------------------------------------------
@@ -131,6 +206,12 @@ type routerImpl struct {
 	authProvider   f.AuthProvider
 	ds             f.DataSource
 	tenantProvider f.TenantProvider
+	info           f.AppInfo
+	routes         *routeRegistry
+	reporter       f.ErrorReporter
+	legacyErrors   bool
+	txRetries      int
+	ready          atomic.Bool
 }
 
 type groupRouterImpl struct {
@@ -138,9 +219,34 @@ type groupRouterImpl struct {
 	internal      *echo.Group
 	tokenProvider f.TokenProvider
 	ds            f.DataSource
+	prefix        string
+	middlewares   []f.Middleware
+	routes        *routeRegistry
+	router        *routerImpl
 	//tenantProvider f.TenantProvider
 }
 
+// routeRegistry keeps track of every route registered through the router so
+// they can be documented.
+type routeRegistry struct {
+	mu     sync.RWMutex
+	routes []f.Route
+}
+
+func (r *routeRegistry) add(route f.Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+}
+
+func (r *routeRegistry) list() []f.Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	routes := make([]f.Route, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
 func (r *routerImpl) Init() {
 
 	r.internal.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -239,12 +345,17 @@ func (r *routerImpl) Handler() http.Handler {
 	return r.internal
 }
 
+func (r *routerImpl) SetReady(ready bool) {
+	r.ready.Store(ready)
+}
+
+// Listen blocks until the server fails or is shut down
 func (r *routerImpl) Listen(port int) error {
 	if port == 0 {
 		port = 8080
 	}
 	err := r.internal.Start(fmt.Sprintf(":%d", port))
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %v", err)
 	}
 	return nil
@@ -255,165 +366,337 @@ func (r *routerImpl) Shutdown(ctx context.Context) error {
 }
 
 func (r *routerImpl) Group(path string, middlewares ...f.Middleware) f.HttpRouter {
-	g := r.internal.Group(path, func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			ctx := &httpContextImpl{
-				internal: c,
-				Context:  c.Request().Context(),
-			}
-			for _, middleware := range middlewares {
-				if err := middleware(ctx); err != nil {
-					return formatError(c, err, 0)
-				}
-			}
-			return next(c)
-		}
-	})
 	return &groupRouterImpl{
-		internal:      g,
+		internal:      r.internal.Group(path, r.groupMiddleware(middlewares)),
 		tokenProvider: r.tokenProvider,
 		ds:            r.ds,
+		prefix:        path,
+		middlewares:   middlewares,
+		routes:        r.routes,
+		router:        r,
 	}
 }
 
+func (r *routerImpl) Routes() []f.Route {
+	return r.routes.list()
+}
+
+func (r *routerImpl) Handle(route f.Route) {
+	r.routes.add(route)
+	r.internal.Add(route.Method, route.Path, r.wrapHandler(route), nativeMiddlewares(route.Middlewares)...)
+}
+
 func (r *routerImpl) GET(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	nativeMiddlewares := make([]echo.MiddlewareFunc, 0)
-	for _, m := range middlewares {
-		if h.IsSameFunc(m, f.GzipMiddleware) {
-			nativeMiddlewares = append(nativeMiddlewares, middleware.Gzip())
-		}
-	}
-	r.internal.GET(path, wrapHandler(handler, r.ds, middlewares...), nativeMiddlewares...)
+	r.Handle(f.Route{Method: http.MethodGet, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *routerImpl) POST(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.POST(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodPost, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *routerImpl) DELETE(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.DELETE(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodDelete, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *routerImpl) PUT(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.PUT(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodPut, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *routerImpl) PATCH(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.PATCH(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodPatch, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 // ----
 
-func (r *groupRouterImpl) GET(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	nativeMiddlewares := make([]echo.MiddlewareFunc, 0)
-	for _, m := range middlewares {
-		if h.IsSameFunc(m, f.GzipMiddleware) {
-			nativeMiddlewares = append(nativeMiddlewares, middleware.Gzip())
-		}
+func (r *groupRouterImpl) Group(path string, middlewares ...f.Middleware) f.HttpRouter {
+	return &groupRouterImpl{
+		internal:      r.internal.Group(path, r.router.groupMiddleware(middlewares)),
+		tokenProvider: r.tokenProvider,
+		ds:            r.ds,
+		prefix:        r.prefix + path,
+		middlewares:   append(append([]f.Middleware{}, r.middlewares...), middlewares...),
+		routes:        r.routes,
+		router:        r.router,
 	}
-	r.internal.GET(path, wrapHandler(handler, r.ds, middlewares...), nativeMiddlewares...)
+}
+
+func (r *groupRouterImpl) Handle(route f.Route) {
+	documented := route
+	documented.Path = r.prefix + route.Path
+	documented.Middlewares = append(append([]f.Middleware{}, r.middlewares...), route.Middlewares...)
+	r.routes.add(documented)
+	r.internal.Add(route.Method, route.Path, r.router.wrapHandler(route), nativeMiddlewares(route.Middlewares)...)
+}
+
+func (r *groupRouterImpl) GET(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
+	r.Handle(f.Route{Method: http.MethodGet, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *groupRouterImpl) POST(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.POST(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodPost, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *groupRouterImpl) DELETE(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.DELETE(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodDelete, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *groupRouterImpl) PUT(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.PUT(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodPut, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 func (r *groupRouterImpl) PATCH(path string, handler func(c f.HttpContext) error, middlewares ...f.Middleware) {
-	r.internal.PATCH(path, wrapHandler(handler, r.ds, middlewares...))
+	r.Handle(f.Route{Method: http.MethodPatch, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
-func wrapHandler(handler func(c f.HttpContext) error, dataSource f.DataSource, middlewares ...f.Middleware) echo.HandlerFunc {
-	return func(c echo.Context) error {
-
-		ctx := &httpContextImpl{
-			internal: c,
-			Context:  c.Request().Context(),
+func (r *routerImpl) groupMiddleware(middlewares []f.Middleware) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := newHttpContext(c)
+			for _, middleware := range middlewares {
+				if err := middleware(ctx); err != nil {
+					return r.formatError(ctx, err, 0)
+				}
+			}
+			return next(c)
 		}
+	}
+}
 
-		inTx := false
+func nativeMiddlewares(middlewares []f.Middleware) []echo.MiddlewareFunc {
+	native := make([]echo.MiddlewareFunc, 0)
+	for _, m := range middlewares {
+		if h.IsSameFunc(m, f.GzipMiddleware) {
+			native = append(native, middleware.Gzip())
+		}
+	}
+	return native
+}
 
-		defer func() {
+func (r *routerImpl) wrapHandler(route f.Route) echo.HandlerFunc {
+	handler := route.Handler
+	middlewares := route.Middlewares
+	dataSource := r.ds
+	txOpts := route.Transaction
+	retries := cmp.Or(txOpts.Retries, r.txRetries)
+	if dataSource == nil || txOpts.Mode == f.NoTx || retries < 0 {
+		retries = 0
+	}
+	return func(c echo.Context) error {
 
-			defaultCnx := ctx.Value(f.DefaultCnxKey{})
-			tenantCnx := ctx.Value(f.TenantCnxKey{})
+		ctx := newHttpContext(c)
+		if route.Feature != "" {
+			ctx.Context = f.WithFeature(ctx.Context, route.Feature)
+		}
+		var releaseScope func()
+		ctx.Context, releaseScope = f.NewRequestScope(ctx.Context)
+		defer releaseScope()
 
-			if err := recover(); err != nil {
-				tracerr.PrintSourceColor(tracerr.Wrap(err.(error)), 1)
-				if inTx {
-					if defaultCnx != nil {
-						defaultCnx.(f.Connection).Rollback()
-					}
-					if tenantCnx != nil {
-						tenantCnx.(f.Connection).Rollback()
-					}
-				}
-				formatError(ctx.internal, err.(error), http.StatusInternalServerError)
-			} else {
-				if inTx {
-					if defaultCnx != nil {
-						defaultCnx.(f.Connection).Commit()
-					}
-					if tenantCnx != nil {
-						tenantCnx.(f.Connection).Commit()
-					}
-				}
-			}
-		}()
+		var span trace.Span
+		ctx.Context, span = startServerSpan(ctx.Context, c)
+		defer endServerSpan(span, c)
 
 		auth := ctx.Auth()
 
 		for _, middleware := range middlewares {
 			if err := middleware(ctx); err != nil {
-				return formatError(ctx.internal, err, http.StatusBadRequest)
+				return r.formatError(ctx, err, http.StatusBadRequest)
 			}
 		}
 
 		tenantId := ctx.TenantId()
 
-		if dataSource != nil {
-			defaultCnx := dataSource.DefaultConnection()
-			if defaultCnx != nil {
-				tx, err := defaultCnx.Tx(ctx)
-				if err != nil {
-					return err
+		if availability, ok := dataSource.(f.TenantAvailability); ok && tenantId != "" && availability.Unavailable(tenantId) != nil {
+			// quarantined tenants are reported by the health checks, not on every request
+			return r.renderError(ctx, errors.ServiceUnavailable(fmt.Sprintf("tenant %s is unavailable", tenantId)), http.StatusServiceUnavailable)
+		}
+
+		ctx.Context = context.WithValue(ctx.Context, f.TenantKey{}, tenantId)
+		ctx.Context = context.WithValue(ctx.Context, f.AuthenticationKey{}, auth)
+
+		var response *bufferedResponse
+		if retries > 0 {
+			response = newBufferedResponse(c.Response())
+		}
+		requestCtx := ctx.Context
+		for attempt := 1; ; attempt++ {
+			ctx.Context = requestCtx
+			panicked, committed, err := r.serve(ctx, handler, txOpts)
+			if !panicked && !committed && attempt <= retries && isSerializationFailure(err) {
+				log.Warn("%s %s: retrying after a serialization failure (%d/%d)", c.Request().Method, c.Path(), attempt, retries)
+				response.reset()
+				continue
+			}
+			if response != nil {
+				if err == nil {
+					return response.flush()
 				}
-				defaultCnx = tx
-				inTx = true
-				ctx.Context = context.WithValue(ctx.Context, f.DefaultCnxKey{}, defaultCnx)
+				response.discard()
 			}
-			if ctx.TenantId() != "" {
-				tenantCnx := dataSource.Connection(ctx.TenantId())
-				if tenantCnx != nil {
-					tx, err := tenantCnx.Tx(ctx)
-					if err != nil {
-						return err
-					}
-					tenantCnx = tx
-					inTx = true
-					ctx.Context = context.WithValue(ctx.Context, f.TenantCnxKey{}, tenantCnx)
+			switch {
+			case err == nil:
+				return nil
+			case c.Response().Committed:
+				// a transaction failed to commit once the response was sent
+				log.Error("%s %s: %v", c.Request().Method, c.Path(), err)
+				if r.reporter != nil {
+					r.reporter.CaptureError(ctx, err)
 				}
+				return nil
+			case panicked:
+				return r.renderError(ctx, err, errorStatus(err, http.StatusInternalServerError))
 			}
+			return r.formatError(ctx, err, 0)
 		}
+	}
+}
 
-		ctx.Context = context.WithValue(ctx.Context, f.TenantKey{}, tenantId)
-		ctx.Context = context.WithValue(ctx.Context, f.AuthenticationKey{}, auth)
-
-		err := handler(ctx)
+// serve runs handler with the default and tenant connections bound to ctx, in transactions
+// begun on first use unless opts.Mode is NoTx. The transactions are committed unless the
+// handler panics or fails on a serialization failure. committed reports whether one of
+// them committed, the handler must then not be retried when the next one fails to.
+func (r *routerImpl) serve(ctx *httpContextImpl, handler func(c f.HttpContext) error, opts f.TxOptions) (panicked bool, committed bool, err error) {
+	var txs []*lazyTx
+	bind := func(cnx f.Connection) f.Connection {
+		if opts.Mode == f.NoTx {
+			return cnx
+		}
+		tx := newLazyTx(cnx, opts)
+		txs = append(txs, tx)
+		return tx
+	}
+	if r.ds != nil {
+		if defaultCnx := r.ds.DefaultConnection(); defaultCnx != nil {
+			ctx.Context = context.WithValue(ctx.Context, f.DefaultCnxKey{}, bind(defaultCnx))
+		}
+		if tenantId := ctx.TenantId(); tenantId != "" {
+			if tenantCnx := r.ds.Connection(tenantId); tenantCnx != nil {
+				ctx.Context = context.WithValue(ctx.Context, f.TenantCnxKey{}, bind(tenantCnx))
+			}
+		}
+	}
 
-		if err != nil {
-			return formatError(ctx.internal, err, 0)
+	defer func() {
+		if value := recover(); value != nil {
+			var ok bool
+			if err, ok = value.(error); !ok {
+				err = fmt.Errorf("%v", value)
+			}
+			// Bind panics with the 400 of malformed requests, they are not bugs to report
+			var customError *errors.CustomError
+			if !stderrors.As(err, &customError) || errorStatus(err, http.StatusInternalServerError) >= http.StatusInternalServerError {
+				tracerr.PrintSourceColor(tracerr.Wrap(err), 1)
+				if r.reporter != nil {
+					r.reporter.CapturePanic(ctx, value)
+				}
+			}
+			panicked = true
+		}
+		// the transactions of a handler returning an error are committed, as they always were
+		rollback := panicked || isSerializationFailure(err)
+		for _, tx := range txs {
+			begun := tx.begun()
+			if rollback {
+				_ = tx.Rollback()
+			} else if commitErr := tx.Commit(); commitErr != nil {
+				// postgres reports serialization failures of serializable transactions on commit
+				err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+				rollback = true
+			} else if begun {
+				committed = true
+			}
 		}
+	}()
 
-		return nil
+	return false, false, handler(ctx)
+}
+
+// bufferedResponse holds the response of a handler retried on serialization failures
+// until its transactions commit.
+type bufferedResponse struct {
+	response *echo.Response
+	writer   http.ResponseWriter
+	initial  http.Header
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+}
+
+func newBufferedResponse(response *echo.Response) *bufferedResponse {
+	b := &bufferedResponse{
+		response: response,
+		writer:   response.Writer,
+		initial:  response.Header().Clone(),
+	}
+	b.header = b.initial.Clone()
+	response.Writer = b
+	return b
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+// Flush is a no-op, the response is written once the transactions commit
+func (b *bufferedResponse) Flush() {}
+
+// reset discards the response of a failed attempt
+func (b *bufferedResponse) reset() {
+	b.header = b.initial.Clone()
+	b.status = 0
+	b.body.Reset()
+	b.response.Committed = false
+	b.response.Status = http.StatusOK
+	b.response.Size = 0
+}
+
+// discard restores the response writer, leaving the response to the error handling
+func (b *bufferedResponse) discard() {
+	b.reset()
+	b.response.Writer = b.writer
+}
+
+// flush writes the held response
+func (b *bufferedResponse) flush() error {
+	b.response.Writer = b.writer
+	header := b.writer.Header()
+	clear(header)
+	maps.Copy(header, b.header)
+	if b.status != 0 {
+		b.writer.WriteHeader(b.status)
+	}
+	_, err := b.writer.Write(b.body.Bytes())
+	return err
+}
+
+// startServerSpan continues the trace propagated by the caller, if any
+func startServerSpan(ctx context.Context, c echo.Context) (context.Context, trace.Span) {
+	req := c.Request()
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(req.Header))
+	return tracer().Start(ctx, req.Method+" "+c.Path(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.HTTPRoute(c.Path()),
+			attribute.String("http.request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+		),
+	)
+}
+
+func endServerSpan(span trace.Span, c echo.Context) {
+	status := c.Response().Status
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
 	}
+	span.End()
 }
 
 // ------------------------------------------------------------------------------------------------------------------
@@ -425,6 +708,16 @@ type httpContextImpl struct {
 	internal echo.Context
 }
 
+func newHttpContext(c echo.Context) *httpContextImpl {
+	ctx := c.Request().Context()
+	ctx = context.WithValue(ctx, f.RequestIdKey{}, c.Response().Header().Get(echo.HeaderXRequestID))
+	ctx = context.WithValue(ctx, f.RouteKey{}, c.Request().Method+" "+c.Path())
+	return &httpContextImpl{
+		internal: c,
+		Context:  ctx,
+	}
+}
+
 func (c *httpContextImpl) Auth() *f.Authentication {
 	value := c.internal.Get(_authKey)
 	if value == nil {
@@ -454,7 +747,8 @@ func (c *httpContextImpl) IdemPotencyKey() string {
 }
 
 func (c *httpContextImpl) TenantId() string {
-	return c.internal.Get(_tenantIdKey).(string)
+	value, _ := c.internal.Get(_tenantIdKey).(string)
+	return value
 }
 
 func (c *httpContextImpl) Param(value string) string {
@@ -469,6 +763,14 @@ func (c *httpContextImpl) Header(value string) string {
 	return c.internal.Request().Header.Get(value)
 }
 
+func (c *httpContextImpl) SetHeader(key string, value string) {
+	c.internal.Response().Header().Set(key, value)
+}
+
+func (c *httpContextImpl) RequestURI() string {
+	return c.internal.Request().URL.RequestURI()
+}
+
 func (c *httpContextImpl) Host() string {
 	return c.internal.Request().Host
 }
@@ -484,24 +786,27 @@ func (c *httpContextImpl) Bind(value any) error {
 func (c *httpContextImpl) ShouldBind(input any) error {
 	binder := &echo.DefaultBinder{}
 	if err := binder.BindHeaders(c.internal, input); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+		return bindingError(err, c.Header("Accept-Language"))
 	}
 	if err := binder.BindQueryParams(c.internal, input); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+		return bindingError(err, c.Header("Accept-Language"))
 	}
 	if err := binder.BindPathParams(c.internal, input); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+		return bindingError(err, c.Header("Accept-Language"))
 	}
 	if err := binder.BindBody(c.internal, input); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+		return bindingError(err, c.Header("Accept-Language"))
 	}
-	validate := validator.New()
-	if err := validate.Struct(input); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	if err := validateInput(input); err != nil {
+		return bindingError(err, c.Header("Accept-Language"))
 	}
 	return nil
 }
 
+func (c *httpContextImpl) Container() *f.Container {
+	return f.ContainerFrom(c.Context)
+}
+
 func (c *httpContextImpl) RemoteAddr() string {
 	return c.internal.RealIP()
 }
@@ -534,36 +839,119 @@ func (c *httpContextImpl) Redirect(status int, url string) error {
 	return c.internal.Redirect(status, url)
 }
 
+func (c *httpContextImpl) PinPrimary() {
+	c.Context = f.PinPrimary(c.Context)
+}
+
 func (c *httpContextImpl) SetTenant(tenantId string) {
 	c.internal.Set(_tenantIdKey, tenantId)
 	c.Context = context.WithValue(c.Context, f.TenantKey{}, tenantId)
 }
 
-func formatError(ctx echo.Context, err error, code int) error {
+// instrumentRequests records the count and latency of requests by route template into m
+func instrumentRequests(m *instruments) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = errorStatus(err, 0)
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			labels := []string{c.Request().Method, route, strconv.Itoa(status)}
+			m.httpRequests.Inc(labels...)
+			m.httpDuration.Observe(seconds(start), labels...)
+			return err
+		}
+	}
+}
+
+func errorStatus(err error, code int) int {
 	status := code
-	if customError, ok := err.(*errors.CustomError); ok {
+	var customError *errors.CustomError
+	var echoError *echo.HTTPError
+	if stderrors.As(err, &customError) {
 		status = customError.Code
-	}
-	if echoError, ok := err.(*echo.HTTPError); ok {
+	} else if stderrors.As(err, &echoError) {
 		status = echoError.Code
 	}
 	if status == 0 {
 		status = http.StatusInternalServerError
 	}
+	return status
+}
+
+// formatError reports server errors and renders err as the response.
+func (r *routerImpl) formatError(c *httpContextImpl, err error, code int) error {
+	status := errorStatus(err, code)
+	if status >= http.StatusInternalServerError && r.reporter != nil {
+		r.reporter.CaptureError(c, err)
+	}
+	return r.renderError(c, err, status)
+}
+
+func (r *routerImpl) renderError(c *httpContextImpl, err error, status int) error {
+	ctx := c.internal
+	var customError *errors.CustomError
+	isCustom := stderrors.As(err, &customError)
 	errorMessage := err.Error()
+	var echoError *echo.HTTPError
+	if !isCustom && stderrors.As(err, &echoError) {
+		errorMessage = fmt.Sprint(echoError.Message)
+	}
 
 	log.Error("http-error: %v -- %v", status, errorMessage)
 
-	return ctx.JSON(status, map[string]any{
-		"requestId": ctx.Response().Header().Get(echo.HeaderXRequestID),
-		"timestamp": time.Now().Format(time.RFC3339),
-		"uri":       ctx.Request().URL.Path,
-		"error":     errorMessage,
-		"success":   false,
-	})
+	requestId := ctx.Response().Header().Get(echo.HeaderXRequestID)
+	timestamp := time.Now().Format(time.RFC3339)
+
+	if r.legacyErrors {
+		return ctx.JSON(status, map[string]any{
+			"requestId": requestId,
+			"timestamp": timestamp,
+			"uri":       ctx.Request().URL.Path,
+			"error":     legacyErrorMessage(err),
+			"success":   false,
+		})
+	}
+
+	errorCode := errors.DefaultErrorCode(status)
+	if isCustom {
+		errorCode = customError.ErrorCode
+	}
+	problem := errors.ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    errorMessage,
+		Instance:  ctx.Request().URL.Path,
+		Code:      errorCode,
+		RequestId: requestId,
+		Timestamp: timestamp,
+	}
+	if isCustom {
+		problem.Errors = customError.Details
+	}
+	ctx.Response().Header().Set(echo.HeaderContentType, problemContentType)
+	return ctx.JSON(status, problem)
 	// return tracerr.Wrap(err)
 }
 
+// legacyErrorMessage returns the text of err in the legacy error body, the one of the
+// echo error binding and validation failures were reported with.
+func legacyErrorMessage(err error) string {
+	var customError *errors.CustomError
+	var echoError *echo.HTTPError
+	if stderrors.As(err, &customError) && stderrors.As(customError.Cause, &echoError) {
+		return echoError.Error()
+	}
+	return err.Error()
+}
+
 func (r *routerImpl) MCP(path string, handler http.Handler) {
 	wrapped := echo.WrapHandler(handler)
 	r.internal.POST(path, wrapped)
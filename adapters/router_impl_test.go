@@ -0,0 +1,625 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/soffa-projects/foundation-go/test"
+)
+
+type createItemInput struct {
+	Name     string `json:"name" validate:"required"`
+	Quantity int    `json:"quantity" validate:"gte=1"`
+}
+
+type itemOutput struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+type getItemInput struct {
+	ID string `param:"id" validate:"required"`
+}
+
+func newTestRouter() f.Router {
+	router := NewEchoRouter(EchoRouterConfig{Env: "test"})
+	router.Init()
+	return router
+}
+
+func serve(router f.Router, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// Typed Handler Tests
+// ------------------------------------------------------------------------------------------------------------------
+
+func TestTypedHandler_BindsAndSerializes(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.Handle(router, f.Route{Method: http.MethodPost, Path: "/items", Status: http.StatusCreated},
+		func(c f.HttpContext, in createItemInput) (itemOutput, error) {
+			return itemOutput{ID: "1", Name: in.Name, Quantity: in.Quantity}, nil
+		})
+
+	rec := serve(router, http.MethodPost, "/items", `{"name":"pen","quantity":2}`)
+	assert.Equals(rec.Code, http.StatusCreated)
+
+	var out itemOutput
+	assert.Nil(json.Unmarshal(rec.Body.Bytes(), &out))
+	assert.Equals(out, itemOutput{ID: "1", Name: "pen", Quantity: 2})
+}
+
+func TestTypedHandler_ValidationFailure(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	called := false
+	f.POST(router, "/items", func(c f.HttpContext, in createItemInput) (itemOutput, error) {
+		called = true
+		return itemOutput{}, nil
+	})
+
+	rec := serve(router, http.MethodPost, "/items", `{"quantity":0}`)
+	assert.Equals(rec.Code, http.StatusBadRequest)
+	assert.False(called)
+	assert.True(strings.Contains(rec.Body.String(), "name"))
+}
+
+func TestTypedHandler_MalformedBody(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.POST(router, "/items", func(c f.HttpContext, in createItemInput) (itemOutput, error) {
+		return itemOutput{}, nil
+	})
+
+	rec := serve(router, http.MethodPost, "/items", `{"name":`)
+	assert.Equals(rec.Code, http.StatusBadRequest)
+}
+
+func TestTypedHandler_PathParams(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.GET(router, "/items/:id", func(c f.HttpContext, in getItemInput) (itemOutput, error) {
+		if in.ID == "missing" {
+			return itemOutput{}, errors.NotFound("ITEM_NOT_FOUND")
+		}
+		return itemOutput{ID: in.ID}, nil
+	})
+
+	rec := serve(router, http.MethodGet, "/items/42", "")
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.True(strings.Contains(rec.Body.String(), `"id":"42"`))
+
+	rec = serve(router, http.MethodGet, "/items/missing", "")
+	assert.Equals(rec.Code, http.StatusNotFound)
+}
+
+func TestTypedHandler_NonStructInputs(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.POST(router, "/items", func(c f.HttpContext, in *createItemInput) (itemOutput, error) {
+		return itemOutput{Name: in.Name}, nil
+	})
+	f.POST(router, "/batch", func(c f.HttpContext, in []createItemInput) (int, error) {
+		return len(in), nil
+	})
+	f.POST(router, "/labels", func(c f.HttpContext, in map[string]string) (string, error) {
+		return in["name"], nil
+	})
+
+	rec := serve(router, http.MethodPost, "/items", `{"name":"pen","quantity":2}`)
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.True(strings.Contains(rec.Body.String(), `"name":"pen"`))
+	// pointed structs are still validated
+	assert.Equals(serve(router, http.MethodPost, "/items", `{"quantity":2}`).Code, http.StatusBadRequest)
+
+	rec = serve(router, http.MethodPost, "/batch", `[{"name":"pen"},{"name":"ink"}]`)
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.Equals(strings.TrimSpace(rec.Body.String()), "2")
+
+	rec = serve(router, http.MethodPost, "/labels", `{"name":"pen"}`)
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.Equals(strings.TrimSpace(rec.Body.String()), `"pen"`)
+}
+
+func TestTypedHandler_NoContent(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.Handle(router, f.Route{Method: http.MethodDelete, Path: "/items/:id", Status: http.StatusNoContent},
+		func(c f.HttpContext, in getItemInput) (f.Empty, error) {
+			return f.Empty{}, nil
+		})
+
+	rec := serve(router, http.MethodDelete, "/items/42", "")
+	assert.Equals(rec.Code, http.StatusNoContent)
+	assert.Equals(rec.Body.Len(), 0)
+}
+
+func TestTypedHandler_DocumentsTypes(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.POST(router, "/items", func(c f.HttpContext, in createItemInput) (itemOutput, error) {
+		return itemOutput{}, nil
+	})
+
+	routes := router.Routes()
+	assert.Equals(len(routes), 1)
+	assert.Equals(routes[0].Input, createItemInput{})
+	assert.Equals(routes[0].Output, itemOutput{})
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// Error Format Tests
+// ------------------------------------------------------------------------------------------------------------------
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) errors.ProblemDetails {
+	var problem errors.ProblemDetails
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	return problem
+}
+
+func TestErrorFormat_ProblemJson(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	router.GET("/items/:id", func(c f.HttpContext) error {
+		return errors.NotFound("item not found")
+	})
+
+	rec := serve(router, http.MethodGet, "/items/42", "")
+	assert.Equals(rec.Code, http.StatusNotFound)
+	assert.Equals(rec.Header().Get("Content-Type"), "application/problem+json")
+
+	problem := decodeProblem(t, rec)
+	assert.Equals(problem.Status, http.StatusNotFound)
+	assert.Equals(problem.Title, "Not Found")
+	assert.Equals(problem.Code, errors.CodeNotFound)
+	assert.Equals(problem.Detail, "item not found")
+	assert.Equals(problem.Instance, "/items/42")
+}
+
+func TestErrorFormat_ValidationDetails(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.POST(router, "/items", func(c f.HttpContext, in createItemInput) (itemOutput, error) {
+		return itemOutput{}, nil
+	})
+
+	rec := serve(router, http.MethodPost, "/items", `{"quantity":0}`)
+	assert.Equals(rec.Code, http.StatusBadRequest)
+
+	problem := decodeProblem(t, rec)
+	assert.Equals(problem.Code, errors.CodeValidationFailed)
+	assert.Equals(len(problem.Errors), 2)
+	assert.Equals(problem.Errors[0].Field, "name")
+	assert.Equals(problem.Errors[0].Tag, "required")
+	assert.Equals(problem.Errors[0].Message, "name is a required field")
+	assert.Equals(problem.Errors[1].Field, "quantity")
+	assert.Equals(problem.Errors[1].Tag, "gte")
+}
+
+func TestErrorFormat_LocalizedValidation(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	f.POST(router, "/items", func(c f.HttpContext, in createItemInput) (itemOutput, error) {
+		return itemOutput{}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"quantity":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fr-FR,fr;q=0.9")
+	rec := httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+
+	problem := decodeProblem(t, rec)
+	assert.Equals(len(problem.Errors), 1)
+	assert.Equals(problem.Errors[0].Message, "name est un champ obligatoire")
+}
+
+func TestErrorFormat_UnknownRoute(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	rec := serve(newTestRouter(), http.MethodGet, "/unknown", "")
+	assert.Equals(rec.Code, http.StatusNotFound)
+	assert.Equals(decodeProblem(t, rec).Code, errors.CodeNotFound)
+}
+
+func TestErrorFormat_Legacy(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", LegacyErrorFormat: true})
+	router.Init()
+	router.GET("/fail", func(c f.HttpContext) error {
+		return errors.Conflict("ALREADY_EXISTS")
+	})
+
+	rec := serve(router, http.MethodGet, "/fail", "")
+	assert.Equals(rec.Code, http.StatusConflict)
+
+	var body map[string]any
+	assert.Nil(json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equals(body["error"], "ALREADY_EXISTS")
+	assert.Equals(body["success"], false)
+	assert.Equals(body["uri"], "/fail")
+}
+
+// TestErrorFormat_LegacyMatchesBaseline compares the legacy bodies with the messages of
+// the echo errors the router used to render
+func TestErrorFormat_LegacyMatchesBaseline(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", LegacyErrorFormat: true})
+	router.Init()
+	router.POST("/items", func(c f.HttpContext) error {
+		var input createItemInput
+		return c.ShouldBind(&input)
+	})
+	router.GET("/echo", func(c f.HttpContext) error {
+		return echo.NewHTTPError(http.StatusNotFound, "missing")
+	})
+	legacyError := func(rec *httptest.ResponseRecorder) any {
+		var body map[string]any
+		assert.Nil(json.Unmarshal(rec.Body.Bytes(), &body))
+		return body["error"]
+	}
+
+	rec := serve(router, http.MethodPost, "/items", `{"quantity":0}`)
+	assert.Equals(rec.Code, http.StatusBadRequest)
+	validationErr := validator.New().Struct(&createItemInput{})
+	assert.Equals(legacyError(rec), echo.NewHTTPError(http.StatusBadRequest, validationErr.Error()).Error())
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	bindErr := (&echo.DefaultBinder{}).BindBody(echo.New().NewContext(req, httptest.NewRecorder()), &createItemInput{})
+	rec = serve(router, http.MethodPost, "/items", "{")
+	assert.Equals(rec.Code, http.StatusBadRequest)
+	assert.Equals(legacyError(rec), echo.NewHTTPError(http.StatusBadRequest, bindErr).Error())
+
+	rec = serve(router, http.MethodGet, "/echo", "")
+	assert.Equals(rec.Code, http.StatusNotFound)
+	assert.Equals(legacyError(rec), "code=404, message=missing")
+}
+
+func TestErrorFormat_WrappedErrors(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	router.GET("/items/:id", func(c f.HttpContext) error {
+		return fmt.Errorf("loading item: %w", errors.Validation(errors.ErrorDetail{Field: "id", Message: "unknown"}))
+	})
+
+	rec := serve(router, http.MethodGet, "/items/1", "")
+	assert.Equals(rec.Code, http.StatusBadRequest)
+	problem := decodeProblem(t, rec)
+	assert.Equals(problem.Code, errors.CodeValidationFailed)
+	assert.Equals(problem.Detail, "loading item: validation failed")
+	assert.Equals(len(problem.Errors), 1)
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// Error Reporting Tests
+// ------------------------------------------------------------------------------------------------------------------
+
+func TestErrorReporting_PanicsAndServerErrors(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	reporter := NewInMemoryErrorReporter()
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", ErrorReporter: reporter})
+	router.Init()
+	router.GET("/panic", func(c f.HttpContext) error {
+		panic("boom")
+	})
+	router.GET("/fail", func(c f.HttpContext) error {
+		return errors.Technical("database unavailable")
+	})
+	router.GET("/missing", func(c f.HttpContext) error {
+		return errors.NotFound("item not found")
+	})
+	router.POST("/items", func(c f.HttpContext) error {
+		var input createItemInput
+		_ = c.Bind(&input)
+		return c.JSON(http.StatusCreated, input)
+	})
+
+	rec := serve(router, http.MethodGet, "/panic", "")
+	assert.Equals(rec.Code, http.StatusInternalServerError)
+	rec = serve(router, http.MethodGet, "/fail", "")
+	assert.Equals(rec.Code, http.StatusInternalServerError)
+	rec = serve(router, http.MethodGet, "/missing", "")
+	assert.Equals(rec.Code, http.StatusNotFound)
+	// malformed requests make Bind panic with a 400
+	rec = serve(router, http.MethodPost, "/items", `{"quantity":1}`)
+	assert.Equals(rec.Code, http.StatusBadRequest)
+
+	reported := reporter.Errors()
+	assert.Equals(len(reported), 2)
+	assert.Equals(reported[0].Panic, "boom")
+	assert.Equals(reported[0].Scope.Route, "GET /panic")
+	assert.True(reported[0].Scope.RequestId != "")
+	assert.Equals(reported[1].Err.Error(), "database unavailable")
+	assert.Equals(reported[1].Scope.Route, "GET /fail")
+}
+
+func TestRouter_ContainerInContext(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	container := f.NewContainer()
+	f.ProvideIn(container, f.AppInfo{Name: "isolated"})
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", Container: container})
+	router.Init()
+	router.GET("/info", func(c f.HttpContext) error {
+		info := f.MustResolveIn[f.AppInfo](c.Container())
+		return c.JSON(http.StatusOK, info.Name)
+	})
+
+	rec := serve(router, http.MethodGet, "/info", "")
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.True(strings.Contains(rec.Body.String(), "isolated"))
+}
+
+func TestRouter_Probes(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	router := newTestRouter()
+	assert.Equals(serve(router, http.MethodGet, "/healthz", "").Code, http.StatusOK)
+	assert.Equals(serve(router, http.MethodGet, "/readyz", "").Code, http.StatusServiceUnavailable)
+
+	router.SetReady(true)
+	assert.Equals(serve(router, http.MethodGet, "/readyz", "").Code, http.StatusOK)
+
+	router.SetReady(false)
+	assert.Equals(serve(router, http.MethodGet, "/healthz", "").Code, http.StatusOK)
+	assert.Equals(serve(router, http.MethodGet, "/readyz", "").Code, http.StatusServiceUnavailable)
+}
+
+func TestRouter_ReadinessChecks(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	healthy := true
+	checks := f.NewHealthChecks(time.Second)
+	checks.AddPing("cache", func() error {
+		if !healthy {
+			return errors.Technical("unreachable")
+		}
+		return nil
+	})
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", HealthChecks: checks})
+	router.Init()
+	router.SetReady(true)
+
+	rec := serve(router, http.MethodGet, "/readyz", "")
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.True(strings.Contains(rec.Body.String(), `"cache":{"status":"UP"}`))
+
+	healthy = false
+	rec = serve(router, http.MethodGet, "/readyz", "")
+	assert.Equals(rec.Code, http.StatusServiceUnavailable)
+	assert.True(strings.Contains(rec.Body.String(), "unreachable"))
+	assert.Equals(serve(router, http.MethodGet, "/healthz", "").Code, http.StatusOK)
+}
+
+func TestRouter_QuarantinedTenant(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: test.TestDatabaseURL()})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{
+			{ID: "broken", DatabaseUrl: "invalid://url"},
+			{ID: "acme", DatabaseUrl: test.TestDatabaseURL()},
+		},
+	})
+	assert.Nil(ds.Init([]f.Feature{}))
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", DataSource: ds})
+	router.Init()
+	router.GET("/items", noopHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("X-TenantId", "broken")
+	rec := httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+	assert.Equals(rec.Code, http.StatusServiceUnavailable)
+	assert.True(strings.Contains(rec.Body.String(), errors.CodeUnavailable))
+
+	req.Header.Set("X-TenantId", "acme")
+	rec = httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+	assert.Equals(rec.Code, http.StatusOK)
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// Transaction Tests
+// ------------------------------------------------------------------------------------------------------------------
+
+// sqlStateError stands for a postgres error carrying the SQLSTATE code
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "sqlstate " + string(e)
+}
+
+func (e sqlStateError) Field(k byte) string {
+	if k == 'C' {
+		return string(e)
+	}
+	return ""
+}
+
+// failingCommitConnection begins transactions failing to commit on a serialization failure
+type failingCommitConnection struct {
+	f.Connection
+}
+
+func (c failingCommitConnection) Tx(ctx context.Context) (f.Connection, error) {
+	tx, err := c.Connection.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return failingCommitConnection{tx}, nil
+}
+
+func (c failingCommitConnection) Commit() error {
+	_ = c.Connection.Rollback()
+	return sqlStateError("40001")
+}
+
+func newTransactionalRouter(t *testing.T) (f.Router, f.Connection) {
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "app.db")})
+	if err := ds.Init([]f.Feature{accountsFeature}); err != nil {
+		t.Fatal(err)
+	}
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", DataSource: ds, TxRetries: 1})
+	router.Init()
+	return router, ds.DefaultConnection()
+}
+
+func countAccounts(t *testing.T, cnx f.Connection) int {
+	count, err := cnx.Count(context.Background(), &account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRouter_LazyTransactions(t *testing.T) {
+	assert := test.NewAssertions(t)
+	router, cnx := newTransactionalRouter(t)
+
+	router.POST("/accounts", func(c f.HttpContext) error {
+		tx, ok := c.Value(f.DefaultCnxKey{}).(*lazyTx)
+		assert.True(ok)
+		assert.True(tx.tx == nil)
+		assert.Nil(tx.Insert(c, &account{ID: "1"}))
+		assert.True(tx.tx != nil)
+		return c.JSON(http.StatusCreated, "created")
+	})
+	router.Handle(f.Route{Method: http.MethodPost, Path: "/plain", Transaction: f.TxOptions{Mode: f.NoTx}, Handler: func(c f.HttpContext) error {
+		_, ok := c.Value(f.DefaultCnxKey{}).(connectionImpl)
+		assert.True(ok)
+		return c.NoContent()
+	}})
+	router.Handle(f.Route{Method: http.MethodGet, Path: "/accounts", Transaction: f.TxOptions{Mode: f.ReadOnlyTx, Isolation: sql.LevelSerializable}, Handler: func(c f.HttpContext) error {
+		tx := c.Value(f.DefaultCnxKey{}).(*lazyTx)
+		assert.Equals(tx.opts, sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true})
+		count, err := tx.Count(c, &account{})
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, count)
+	}})
+	router.POST("/panic", func(c f.HttpContext) error {
+		assert.Nil(c.Value(f.DefaultCnxKey{}).(f.Connection).Insert(c, &account{ID: "2"}))
+		panic("boom")
+	})
+
+	assert.Equals(serve(router, http.MethodPost, "/accounts", "").Code, http.StatusCreated)
+	assert.Equals(countAccounts(t, cnx), 1)
+	rec := serve(router, http.MethodGet, "/accounts", "")
+	assert.Equals(rec.Code, http.StatusOK)
+	assert.Equals(strings.TrimSpace(rec.Body.String()), "1")
+	assert.Equals(serve(router, http.MethodPost, "/plain", "").Code, http.StatusNoContent)
+	assert.Equals(serve(router, http.MethodPost, "/panic", "").Code, http.StatusInternalServerError)
+	assert.Equals(countAccounts(t, cnx), 1)
+}
+
+func TestRouter_RetriesSerializationFailures(t *testing.T) {
+	assert := test.NewAssertions(t)
+	router, cnx := newTransactionalRouter(t)
+
+	attempts := 0
+	router.Handle(f.Route{Method: http.MethodPost, Path: "/accounts", Transaction: f.TxOptions{Retries: 2}, Handler: func(c f.HttpContext) error {
+		attempts++
+		id := strconv.Itoa(attempts)
+		assert.Nil(c.Value(f.DefaultCnxKey{}).(f.Connection).Insert(c, &account{ID: id}))
+		c.SetHeader("X-Attempt", id)
+		if err := c.JSON(http.StatusCreated, id); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return sqlStateError("40001")
+		}
+		return nil
+	}})
+	router.POST("/conflict", func(c f.HttpContext) error {
+		attempts++
+		return sqlStateError("40P01")
+	})
+
+	rec := serve(router, http.MethodPost, "/accounts", "")
+	assert.Equals(attempts, 3)
+	assert.Equals(rec.Code, http.StatusCreated)
+	assert.Equals(rec.Header().Get("X-Attempt"), "3")
+	assert.Equals(strings.TrimSpace(rec.Body.String()), `"3"`)
+	assert.Equals(countAccounts(t, cnx), 1)
+
+	// the router retries once by default
+	attempts = 0
+	rec = serve(router, http.MethodPost, "/conflict", "")
+	assert.Equals(attempts, 2)
+	assert.Equals(rec.Code, http.StatusInternalServerError)
+}
+
+func TestRouter_DoesNotRetryPartialCommits(t *testing.T) {
+	assert := test.NewAssertions(t)
+	ds := NewMultiTenantDS(f.DataSourceConfig{DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "app.db")})
+	ds.UseTenantProvider(&mockTenantProvider{
+		tenants: []f.Tenant{{ID: "acme", DatabaseUrl: "sqlite://" + filepath.Join(t.TempDir(), "acme.db")}},
+	})
+	assert.Nil(ds.Init([]f.Feature{{Name: "accounts", FS: fstest.MapFS{
+		"db/migrations/shared/001_accounts.sql": createTableMigration("accounts"),
+		"db/migrations/tenant/001_accounts.sql": createTableMigration("accounts"),
+	}}}))
+	ds.tenants["acme"] = failingCommitConnection{ds.tenants["acme"]}
+	router := NewEchoRouter(EchoRouterConfig{Env: "test", DataSource: ds, TxRetries: 2})
+	router.Init()
+
+	attempts := 0
+	router.POST("/accounts", func(c f.HttpContext) error {
+		attempts++
+		id := strconv.Itoa(attempts)
+		assert.Nil(c.Value(f.DefaultCnxKey{}).(f.Connection).Insert(c, &account{ID: id}))
+		assert.Nil(c.Value(f.TenantCnxKey{}).(f.Connection).Insert(c, &account{ID: id}))
+		return c.JSON(http.StatusCreated, id)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+	req.Header.Set("X-TenantId", "acme")
+	rec := httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+	// the default transaction committed before the tenant one failed to
+	assert.Equals(attempts, 1)
+	assert.Equals(rec.Code, http.StatusInternalServerError)
+	assert.Equals(countAccounts(t, ds.DefaultConnection()), 1)
+}
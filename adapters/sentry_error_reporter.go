@@ -1,7 +1,9 @@
 package adapters
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	f "github.com/soffa-projects/foundation-go/core"
@@ -23,3 +25,53 @@ func NewSentryErrorReporter(dsn string, env string) f.ErrorReporter {
 		client: sentry.CurrentHub().Client(),
 	}
 }
+
+func (r *SentryErrorReporter) CaptureError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	r.withScope(ctx, func(hub *sentry.Hub) {
+		hub.CaptureException(err)
+	})
+}
+
+func (r *SentryErrorReporter) CapturePanic(ctx context.Context, value any) {
+	r.withScope(ctx, func(hub *sentry.Hub) {
+		hub.RecoverWithContext(ctx, value)
+	})
+}
+
+func (r *SentryErrorReporter) AddBreadcrumb(ctx context.Context, breadcrumb f.Breadcrumb) {
+	r.hub(ctx).AddBreadcrumb(&sentry.Breadcrumb{
+		Category:  breadcrumb.Category,
+		Message:   breadcrumb.Message,
+		Level:     sentry.Level(breadcrumb.Level),
+		Data:      breadcrumb.Data,
+		Timestamp: time.Now(),
+	}, nil)
+}
+
+func (r *SentryErrorReporter) Flush(timeout time.Duration) bool {
+	return sentry.Flush(timeout)
+}
+
+func (r *SentryErrorReporter) hub(ctx context.Context) *sentry.Hub {
+	if ctx != nil {
+		if hub := sentry.GetHubFromContext(ctx); hub != nil {
+			return hub
+		}
+	}
+	return sentry.CurrentHub()
+}
+
+func (r *SentryErrorReporter) withScope(ctx context.Context, capture func(hub *sentry.Hub)) {
+	errorScope := f.ErrorScopeFromContext(ctx)
+	hub := r.hub(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(errorScope.Tags())
+		if errorScope.UserId != "" {
+			scope.SetUser(sentry.User{ID: errorScope.UserId})
+		}
+		capture(hub)
+	})
+}
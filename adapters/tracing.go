@@ -0,0 +1,250 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/h"
+	"github.com/soffa-projects/foundation-go/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/soffa-projects/foundation-go"
+
+// traceField is the JSON field carrying the trace context in job payloads and pubsub messages
+const traceField = "_trace"
+
+// ------------------------------------------------------------------------------------------------------------------
+// TRACING IMPL
+// ------------------------------------------------------------------------------------------------------------------
+
+// Tracing owns the OpenTelemetry tracer provider installed globally by NewTracing.
+type Tracing struct {
+	provider *sdktrace.TracerProvider
+	memory   *tracetest.InMemoryExporter
+	file     io.Closer
+}
+
+// NewTracing installs a global tracer provider exporting spans to exporter:
+//
+//	memory://                      in-memory, spans are available with Spans()
+//	stdout://                      pretty printed to the standard output
+//	file:///var/log/traces.json    appended to a file
+//	otlp://collector:4318          OTLP over HTTP (otlp+https:// for TLS)
+//
+// The provider and the propagator are process-wide: with several apps in one process,
+// the spans of all of them go to the exporter of the last app calling NewTracing, and
+// are dropped once that app is stopped.
+func NewTracing(exporter string, info f.AppInfo) (*Tracing, error) {
+	if exporter == "memory" {
+		exporter = "memory://"
+	}
+	cfg, err := h.ParseUrl(exporter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tracing exporter: %v", err)
+	}
+	tracing := &Tracing{}
+	var spanExporter sdktrace.SpanExporter
+	switch cfg.Scheme {
+	case "memory", "faker":
+		tracing.memory = tracetest.NewInMemoryExporter()
+		spanExporter = tracing.memory
+	case "stdout":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		file, ferr := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if ferr != nil {
+			return nil, fmt.Errorf("failed to open trace file: %v", ferr)
+		}
+		tracing.file = file
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	case "otlp", "otlp+http", "otlp+https":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Host)}
+		if cfg.Path != "" && cfg.Path != "/" {
+			opts = append(opts, otlptracehttp.WithURLPath(cfg.Path))
+		}
+		if cfg.Scheme != "otlp+https" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		spanExporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", cfg.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+
+	var processor sdktrace.SpanProcessor
+	if tracing.memory != nil {
+		// spans must be visible as soon as they end
+		processor = sdktrace.NewSimpleSpanProcessor(spanExporter)
+	} else {
+		processor = sdktrace.NewBatchSpanProcessor(spanExporter)
+	}
+	tracing.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(info.Name),
+			semconv.ServiceVersion(info.Version),
+		)),
+	)
+	otel.SetTracerProvider(tracing.provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	log.Info("[tracing] exporting spans to %s", cfg.Scheme)
+	return tracing, nil
+}
+
+func MustNewTracing(exporter string, info f.AppInfo) *Tracing {
+	tracing, err := NewTracing(exporter, info)
+	if err != nil {
+		panic(err)
+	}
+	return tracing
+}
+
+func (t *Tracing) Provider() trace.TracerProvider {
+	return t.provider
+}
+
+// Spans returns the spans ended so far, only available with the memory exporter
+func (t *Tracing) Spans() tracetest.SpanStubs {
+	if t.memory == nil {
+		return nil
+	}
+	return t.memory.GetSpans()
+}
+
+// Reset drops the spans recorded by the memory exporter
+func (t *Tracing) Reset() {
+	if t.memory != nil {
+		t.memory.Reset()
+	}
+}
+
+// Stop flushes pending spans, called by the container on shutdown
+func (t *Tracing) Stop(ctx context.Context) error {
+	err := t.provider.Shutdown(ctx)
+	if t.file != nil {
+		if cerr := t.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// PROPAGATION
+// ------------------------------------------------------------------------------------------------------------------
+
+// tracer uses the global provider so instrumentation is a no-op until NewTracing is called
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTrace appends the trace context of ctx to payload when it is a JSON object,
+// the fields of payload are kept as they are, other payloads are returned as is.
+func injectTrace(ctx context.Context, payload []byte) []byte {
+	if !trace.SpanContextFromContext(ctx).IsValid() || !isJsonObject(payload) {
+		return payload
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	value, err := json.Marshal(carrier)
+	if err != nil {
+		return payload
+	}
+	body := strings.TrimRightFunc(string(payload), unicode.IsSpace)
+	body = strings.TrimSuffix(body, "}")
+	separator := ","
+	if strings.HasSuffix(strings.TrimRightFunc(body, unicode.IsSpace), "{") {
+		separator = ""
+	}
+	return []byte(body + separator + `"` + traceField + `":` + string(value) + "}")
+}
+
+// extractTrace restores the trace context appended by injectTrace and returns the
+// payload without it. A traceField which is not the last field of payload or does
+// not hold a valid trace context belongs to the payload and is left in place.
+func extractTrace(ctx context.Context, payload []byte) (context.Context, []byte) {
+	if !isJsonObject(payload) {
+		return ctx, payload
+	}
+	body := strings.TrimRightFunc(string(payload), unicode.IsSpace)
+	index := strings.LastIndex(body, `"`+traceField+`":`)
+	if index < 0 {
+		return ctx, payload
+	}
+	var carrier propagation.MapCarrier
+	value := strings.TrimSuffix(body[index+len(traceField)+3:], "}")
+	if err := json.Unmarshal([]byte(value), &carrier); err != nil {
+		return ctx, payload
+	}
+	propagator := otel.GetTextMapPropagator()
+	if !trace.SpanContextFromContext(propagator.Extract(context.Background(), carrier)).IsValid() {
+		return ctx, payload
+	}
+	out := strings.TrimRightFunc(body[:index], unicode.IsSpace)
+	out = strings.TrimSuffix(out, ",") + "}"
+	if !json.Valid([]byte(out)) {
+		return ctx, payload
+	}
+	return propagator.Extract(ctx, carrier), []byte(out)
+}
+
+func isJsonObject(payload []byte) bool {
+	trimmed := strings.TrimSpace(string(payload))
+	return strings.HasPrefix(trimmed, "{") && json.Valid(payload)
+}
+
+// publishSpan starts a producer span for a pubsub message and carries it in the message
+func publishSpan(ctx context.Context, topic string, message string) (context.Context, trace.Span, string) {
+	ctx, span := tracer().Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingDestinationName(topic),
+			attribute.String("messaging.operation.type", "publish"),
+		),
+	)
+	return ctx, span, string(injectTrace(ctx, []byte(message)))
+}
+
+// receiveMessage runs handler in a consumer span linked to the publisher one
+func receiveMessage(ctx context.Context, topic string, message string, handler func(ctx context.Context, message string)) {
+	ctx, payload := extractTrace(ctx, []byte(message))
+	ctx, span := tracer().Start(ctx, "receive "+topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingDestinationName(topic),
+			attribute.String("messaging.operation.type", "receive"),
+		),
+	)
+	defer span.End()
+	handler(ctx, string(payload))
+}
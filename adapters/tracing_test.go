@@ -0,0 +1,174 @@
+package adapters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/test"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const remoteTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newTestTracing(t *testing.T) *Tracing {
+	tracing, err := NewTracing("memory://", f.AppInfo{Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to create tracing: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = tracing.Stop(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+	return tracing
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func TestNewTracing_Exporters(t *testing.T) {
+	assert := test.NewAssertions(t)
+
+	tracing, err := NewTracing("file://"+filepath.Join(t.TempDir(), "traces.json"), f.AppInfo{Name: "test"})
+	assert.Nil(err)
+	assert.Nil(tracing.Stop(context.Background()))
+	assert.Equals(len(tracing.Spans()), 0)
+
+	_, err = NewTracing("jaeger://localhost", f.AppInfo{Name: "test"})
+	assert.NotNil(err)
+	otel.SetTracerProvider(noop.NewTracerProvider())
+}
+
+func TestTracing_HttpSpanContinuesRemoteTrace(t *testing.T) {
+	assert := test.NewAssertions(t)
+	tracing := newTestTracing(t)
+
+	router := NewEchoRouter(EchoRouterConfig{Env: "test"})
+	router.Init()
+	var handlerTrace trace.SpanContext
+	router.GET("/items/:id", func(c f.HttpContext) error {
+		handlerTrace = trace.SpanContextFromContext(c)
+		return c.JSON(http.StatusOK, map[string]string{"id": c.Param("id")})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set("traceparent", remoteTraceparent)
+	rec := httptest.NewRecorder()
+	router.Handler().ServeHTTP(rec, req)
+	assert.Equals(rec.Code, http.StatusOK)
+
+	span := findSpan(tracing.Spans(), "GET /items/:id")
+	assert.NotNil(span)
+	assert.Equals(span.SpanKind, trace.SpanKindServer)
+	assert.Equals(span.SpanContext.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equals(span.Parent.SpanID().String(), "00f067aa0ba902b7")
+	assert.Equals(handlerTrace.SpanID(), span.SpanContext.SpanID())
+}
+
+func TestTracing_QuerySpans(t *testing.T) {
+	assert := test.NewAssertions(t)
+	tracing := newTestTracing(t)
+
+	cnx, err := NewConnection(test.TestDatabaseURL())
+	assert.Nil(err)
+	tracing.Reset()
+
+	assert.Nil(cnx.Ping())
+	spans := tracing.Spans()
+	assert.Equals(len(spans), 1)
+	assert.Equals(spans[0].SpanKind, trace.SpanKindClient)
+}
+
+func TestTracing_JobPayloadCarriesTrace(t *testing.T) {
+	assert := test.NewAssertions(t)
+	tracing := newTestTracing(t)
+
+	ctx, parent := tracer().Start(context.Background(), "enqueue")
+	payload := injectTrace(ctx, []byte(`{"email":"john@local.dev"}`))
+	parent.End()
+
+	var received string
+	var jobTrace trace.SpanContext
+	err := runJob(context.Background(), noopInstruments, nil, f.Job{ID: "1", Type: "send_email", Payload: payload}, func(ctx context.Context, job f.Job) error {
+		received = string(job.Payload)
+		jobTrace = trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equals(received, `{"email":"john@local.dev"}`)
+	assert.Equals(jobTrace.TraceID(), parent.SpanContext().TraceID())
+
+	span := findSpan(tracing.Spans(), "job send_email")
+	assert.NotNil(span)
+	assert.Equals(span.Parent.SpanID(), parent.SpanContext().SpanID())
+
+	// payloads which are not JSON objects are left untouched
+	assert.Equals(string(injectTrace(ctx, []byte(`"plain"`))), `"plain"`)
+}
+
+func TestTracing_PubSubMessageCarriesTrace(t *testing.T) {
+	assert := test.NewAssertions(t)
+	tracing := newTestTracing(t)
+
+	pubsub := NewFakePubSubProvider()
+	done := make(chan string, 1)
+	pubsub.Subscribe(context.Background(), "orders", func(ctx context.Context, message string) {
+		done <- trace.SpanContextFromContext(ctx).TraceID().String() + " " + message
+	})
+
+	ctx, parent := tracer().Start(context.Background(), "request")
+	assert.Nil(pubsub.Publish(ctx, "orders", `{"id":"1"}`))
+	parent.End()
+
+	select {
+	case got := <-done:
+		assert.Equals(got, parent.SpanContext().TraceID().String()+` {"id":"1"}`)
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+	assert.NotNil(findSpan(tracing.Spans(), "publish orders"))
+}
+
+func TestTracing_PayloadsKeepTheirFields(t *testing.T) {
+	assert := test.NewAssertions(t)
+	newTestTracing(t)
+
+	ctx, parent := tracer().Start(context.Background(), "enqueue")
+	defer parent.End()
+
+	// the fields keep their order and formatting
+	payload := injectTrace(ctx, []byte(`{"zone": "eu", "amount": 1.50, "id": "1"}`))
+	assert.True(strings.HasPrefix(string(payload), `{"zone": "eu", "amount": 1.50, "id": "1","_trace":`))
+	traced, out := extractTrace(context.Background(), payload)
+	assert.Equals(string(out), `{"zone": "eu", "amount": 1.50, "id": "1"}`)
+	assert.Equals(trace.SpanContextFromContext(traced).TraceID(), parent.SpanContext().TraceID())
+
+	payload = injectTrace(ctx, []byte(`{ }`))
+	_, out = extractTrace(context.Background(), payload)
+	assert.Equals(string(out), `{}`)
+
+	// a _trace field of the payload itself is left in place
+	own := `{"_trace":{"step":"checkout"},"id":"1"}`
+	traced, out = extractTrace(context.Background(), []byte(own))
+	assert.Equals(string(out), own)
+	assert.False(trace.SpanContextFromContext(traced).IsValid())
+	own = `{"id":"1","_trace":{"step":"checkout"}}`
+	_, out = extractTrace(context.Background(), []byte(own))
+	assert.Equals(string(out), own)
+	_, out = extractTrace(context.Background(), injectTrace(ctx, []byte(own)))
+	assert.Equals(string(out), own)
+}
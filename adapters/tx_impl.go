@@ -0,0 +1,264 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	f "github.com/soffa-projects/foundation-go/core"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// LAZY TRANSACTIONS
+// ------------------------------------------------------------------------------------------------------------------
+
+// lazyTx is a transaction of cnx begun on its first query, a request that does not query
+// a connection does not hold it.
+type lazyTx struct {
+	cnx  f.Connection
+	opts sql.TxOptions
+	mu   sync.Mutex
+	tx   f.Connection
+}
+
+func newLazyTx(cnx f.Connection, opts f.TxOptions) *lazyTx {
+	return &lazyTx{
+		cnx: cnx,
+		opts: sql.TxOptions{
+			Isolation: opts.Isolation,
+			ReadOnly:  opts.Mode == f.ReadOnlyTx,
+		},
+	}
+}
+
+func (l *lazyTx) begin(ctx context.Context) (f.Connection, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tx != nil {
+		return l.tx, nil
+	}
+	var (
+		tx  f.Connection
+		err error
+	)
+	if impl, ok := l.cnx.(connectionImpl); ok {
+		tx, err = impl.beginTx(ctx, &l.opts)
+	} else {
+		tx, err = l.cnx.Tx(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.tx = tx
+	return tx, nil
+}
+
+// begun reports whether the transaction was begun, its commit applying the writes of the
+// connection
+func (l *lazyTx) begun() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tx != nil
+}
+
+// end returns the transaction once begun and forgets it
+func (l *lazyTx) end() f.Connection {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tx := l.tx
+	l.tx = nil
+	return tx
+}
+
+func (l *lazyTx) DatabaseUrl() string {
+	return l.cnx.DatabaseUrl()
+}
+
+func (l *lazyTx) SetSchema(schema string) error {
+	return l.cnx.SetSchema(schema)
+}
+
+func (l *lazyTx) Tx(ctx context.Context) (f.Connection, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Tx(ctx)
+}
+
+func (l *lazyTx) Transaction(ctx context.Context, fn func(tx f.Connection) error) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.Transaction(ctx, fn)
+}
+
+// Commit commits the transaction if it was begun
+func (l *lazyTx) Commit() error {
+	if tx := l.end(); tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// Rollback rolls the transaction back if it was begun
+func (l *lazyTx) Rollback() error {
+	if tx := l.end(); tx != nil {
+		return tx.Rollback()
+	}
+	return nil
+}
+
+func (l *lazyTx) Ping() error {
+	return l.cnx.Ping()
+}
+
+func (l *lazyTx) FindBy(ctx context.Context, model f.Entity, where string, args ...any) (bool, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	return tx.FindBy(ctx, model, where, args...)
+}
+
+func (l *lazyTx) ExistsBy(ctx context.Context, model f.Entity, where string, args ...any) (bool, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	return tx.ExistsBy(ctx, model, where, args...)
+}
+
+func (l *lazyTx) Count(ctx context.Context, model f.Entity) (int, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return tx.Count(ctx, model)
+}
+
+func (l *lazyTx) CountBy(ctx context.Context, model f.Entity, where string, args ...any) (int, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return tx.CountBy(ctx, model, where, args...)
+}
+
+func (l *lazyTx) FindByJoin(ctx context.Context, model f.Entity, join string, where string, args ...any) (bool, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	return tx.FindByJoin(ctx, model, join, where, args...)
+}
+
+func (l *lazyTx) CountByJoin(ctx context.Context, model f.Entity, join string, where string, args ...any) (int, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return tx.CountByJoin(ctx, model, join, where, args...)
+}
+
+func (l *lazyTx) Query(ctx context.Context, model f.Entity, opts ...f.QueryOpts) (bool, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	return tx.Query(ctx, model, opts...)
+}
+
+func (l *lazyTx) Paginate(ctx context.Context, models f.Entity, req f.PageRequest, opts ...f.QueryOpts) (f.PageInfo, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return f.PageInfo{}, err
+	}
+	return tx.Paginate(ctx, models, req, opts...)
+}
+
+func (l *lazyTx) Insert(ctx context.Context, model f.Entity) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.Insert(ctx, model)
+}
+
+func (l *lazyTx) InsertBatch(ctx context.Context, models f.Entity) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.InsertBatch(ctx, models)
+}
+
+func (l *lazyTx) Upsert(ctx context.Context, model f.Entity, conflictColumns ...string) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.Upsert(ctx, model, conflictColumns...)
+}
+
+func (l *lazyTx) Update(ctx context.Context, model f.Entity, columns ...string) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.Update(ctx, model, columns...)
+}
+
+func (l *lazyTx) UpdateBy(ctx context.Context, entity f.Entity, columns []string, where string, args ...any) (int64, error) {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return tx.UpdateBy(ctx, entity, columns, where, args...)
+}
+
+func (l *lazyTx) Delete(ctx context.Context, model f.Entity) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.Delete(ctx, model)
+}
+
+func (l *lazyTx) DeleteBy(ctx context.Context, model f.Entity, where string, args ...any) error {
+	tx, err := l.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.DeleteBy(ctx, model, where, args...)
+}
+
+// runInTx runs fn with tx, committing tx when fn succeeds and rolling it back when fn
+// fails or panics
+func runInTx(tx f.Connection, fn func(tx f.Connection) error) error {
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+	return tx.Commit()
+}
+
+// isSerializationFailure reports whether err aborted a postgres transaction that can be
+// retried: a serialization failure or a deadlock
+func isSerializationFailure(err error) bool {
+	// implemented by pgdriver.Error, 'C' being the SQLSTATE
+	var pgErr interface{ Field(k byte) string }
+	if err == nil || !errors.As(err, &pgErr) {
+		return false
+	}
+	code := pgErr.Field('C')
+	return code == "40001" || code == "40P01"
+}
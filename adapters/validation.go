@@ -0,0 +1,115 @@
+package adapters
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/labstack/echo/v4"
+	"github.com/soffa-projects/foundation-go/errors"
+	"github.com/soffa-projects/foundation-go/log"
+	"golang.org/x/text/language"
+)
+
+var inputValidator, validationTranslators = newInputValidator()
+
+// validateInput validates the struct input points to, through any number of pointers.
+// Slices, maps and the other kinds of input carry no validation tags.
+func validateInput(input any) error {
+	value := reflect.ValueOf(input)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return inputValidator.Struct(value.Interface())
+}
+
+func newInputValidator() (*validator.Validate, *ut.UniversalTranslator) {
+	v := validator.New()
+	// report fields with the name used on the wire rather than the Go field name
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "param", "query", "header"} {
+			if name := tagName(field, tag); name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	english := en.New()
+	translators := ut.New(english, english, fr.New(), es.New())
+	register := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": en_translations.RegisterDefaultTranslations,
+		"fr": fr_translations.RegisterDefaultTranslations,
+		"es": es_translations.RegisterDefaultTranslations,
+	}
+	for locale, fn := range register {
+		trans, _ := translators.GetTranslator(locale)
+		if err := fn(v, trans); err != nil {
+			log.Warn("[validator] unable to register %s translations: %v", locale, err)
+		}
+	}
+	return v, translators
+}
+
+// validationTranslator picks the translator matching an Accept-Language header,
+// falling back to english.
+func validationTranslator(acceptLanguage string) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		locales = append(locales, base.String())
+	}
+	trans, _ := validationTranslators.FindTranslator(locales...)
+	return trans
+}
+
+// bindingError converts binder and validator failures into a 400 error. Validation
+// failures list every invalid field with a message in the requested language. The cause
+// of the error is the echo error of the legacy error format.
+func bindingError(err error, acceptLanguage string) error {
+	if echoError, ok := err.(*echo.HTTPError); ok {
+		return errors.New(echoError.Code, "", fmt.Sprint(echoError.Message)).WithCause(echo.NewHTTPError(http.StatusBadRequest, err))
+	}
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		trans := validationTranslator(acceptLanguage)
+		details := make([]errors.ErrorDetail, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			details = append(details, errors.ErrorDetail{
+				Field:   fieldError.Field(),
+				Tag:     fieldError.Tag(),
+				Message: fieldError.Translate(trans),
+			})
+		}
+		return errors.New(http.StatusBadRequest, errors.CodeValidationFailed, "validation failed").
+			WithDetails(details...).
+			WithCause(echo.NewHTTPError(http.StatusBadRequest, legacyValidationMessage(validationErrors)))
+	}
+	return errors.New(http.StatusBadRequest, "", err.Error()).WithCause(echo.NewHTTPError(http.StatusBadRequest, err))
+}
+
+// legacyValidationMessage formats validationErrors like the validator without the wire
+// names of the fields, as the legacy error format always did
+func legacyValidationMessage(validationErrors validator.ValidationErrors) string {
+	messages := make([]string, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		messages = append(messages, fmt.Sprintf("Key: '%s' Error:Field validation for '%s' failed on the '%s' tag",
+			fieldError.StructNamespace(), fieldError.StructField(), fieldError.Tag()))
+	}
+	return strings.Join(messages, "\n")
+}
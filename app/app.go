@@ -3,6 +3,9 @@ package app
 import (
 	"context"
 	"fmt"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	adapters "github.com/soffa-projects/foundation-go/adapters"
@@ -28,7 +31,10 @@ type builderConfig struct {
 	cacheProvider       string
 	secretProvider      f.SecretsProvider
 	errorReporter       string
+	tracingExporter     string
+	outbox              bool
 	queueProvider       string
+	queueWorker         string
 	tokenProvider       *f.JwtConfig
 	dsConfig            []f.DataSourceConfig
 	tenantProvider      string
@@ -38,6 +44,11 @@ type builderConfig struct {
 	idempotencyProvider *IdempotencyProvider
 	authProvider        f.AuthProvider
 	logLevel            string
+	logFormat           string
+	container           *f.Container
+	port                int
+	shutdownTimeout     time.Duration
+	healthCheckTimeout  time.Duration
 }
 
 type AppBuilder struct {
@@ -46,38 +57,95 @@ type AppBuilder struct {
 
 type appImpl struct {
 	f.App
-	router     f.Router
-	instanceId string
+	router          f.Router
+	instanceId      string
+	worker          f.QueueWorker
+	reporter        f.ErrorReporter
+	container       *f.Container
+	port            int
+	shutdownTimeout time.Duration
+	shutdownOnce    sync.Once
 }
 
+const (
+	defaultShutdownTimeout    = 30 * time.Second
+	defaultHealthCheckTimeout = 2 * time.Second
+)
+
 func (app *appImpl) Start(port int) error {
-	defer func() {
-		app.Shutdown(context.Background())
-	}()
+	return app.run(context.Background(), port)
+}
+
+// Run serves requests until ctx is cancelled or SIGINT/SIGTERM is received, then
+// drains in-flight requests and jobs and closes every provider.
+func (app *appImpl) Run(ctx context.Context) error {
+	return app.run(ctx, app.port)
+}
+
+func (app *appImpl) run(ctx context.Context, port int) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if app.worker != nil {
+		log.Info("starting queue worker...")
+		if err := app.worker.Start(); err != nil {
+			app.Shutdown(context.Background())
+			return fmt.Errorf("failed to start queue worker: %v", err)
+		}
+	}
 
 	log.Info("starting webserver...")
-	if err := app.router.Listen(port); err != nil {
-		return fmt.Errorf("failed to start server: %v", err)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.router.Listen(port)
+	}()
+
+	var err error
+	select {
+	case err = <-listenErr:
+		if err != nil {
+			err = fmt.Errorf("failed to start server: %v", err)
+		}
+	case <-ctx.Done():
+		log.Info("shutdown requested, draining for up to %s...", app.shutdownTimeout)
 	}
-	return nil
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
+	defer cancel()
+	app.Shutdown(shutdownCtx)
+	return err
 }
 
 func (app *appImpl) Router() f.Router {
 	return app.router
 }
 
+func (app *appImpl) Container() *f.Container {
+	return app.container
+}
+
+// Shutdown flips readiness off, stops accepting requests and waits for in-flight
+// requests and jobs until ctx is done, then stops every component. Only the first
+// call has an effect.
 func (app *appImpl) Shutdown(ctx context.Context) {
-	if err := app.router.Shutdown(ctx); err != nil {
-		log.Error("error shutting down server: %v", err)
-	}
-	/*
-		TODO: implement shutdown hooks for all providers
-		if app.env.secretProvider != nil {
-			if err := app.env.secretProvider.Close(); err != nil {
-				log.Error("error shutting down secret provider: %v", err)
+	app.shutdownOnce.Do(func() {
+		app.router.SetReady(false)
+		if err := app.router.Shutdown(ctx); err != nil {
+			log.Error("error shutting down server: %v", err)
+		}
+		if app.worker != nil {
+			if err := app.worker.Shutdown(ctx); err != nil {
+				log.Error("error shutting down queue worker: %v", err)
 			}
-		}*/
-	log.Info("shutdown complete")
+		}
+		if app.reporter != nil {
+			app.reporter.Flush(2 * time.Second)
+		}
+		if err := app.container.Stop(ctx); err != nil {
+			log.Error("error stopping components: %v", err)
+		}
+		log.Info("shutdown complete")
+	})
 }
 
 func New(name string, version string, envName string) AppBuilder {
@@ -96,14 +164,22 @@ func New(name string, version string, envName string) AppBuilder {
 // For cases where you want initialization failures to panic, use MustInit() instead.
 func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 
+	production := h.IsProduction(app.config.envName)
+
 	log.Init(app.config.logLevel)
+	logFormat := app.config.logFormat
+	if logFormat == "" {
+		logFormat = log.FormatPretty
+		if production {
+			logFormat = log.FormatJSON
+		}
+	}
+	log.SetFormat(logFormat)
 
 	log.Info("initializing application...")
 
 	h.InitIdGenerator(0)
 
-	production := h.IsProduction(app.config.envName)
-
 	cfg := app.config
 	appInfo := f.AppInfo{
 		Name:      cfg.appName,
@@ -111,6 +187,16 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 		PublicURL: cfg.publicURL,
 	}
 	instanceId := cfg.instanceId
+	container := cfg.container
+	if container == nil {
+		container = f.NewContainer()
+	}
+	// the package level functions of the features resolve from the app
+	f.SetDefaultContainer(container)
+	shutdownTimeout := cfg.shutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
 
 	/*env := applicationEnvImpl{
 		appName:    app.config.appName,
@@ -126,9 +212,21 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 	}
 	features = orderedFeatures
 
+	healthCheckTimeout := cfg.healthCheckTimeout
+	if healthCheckTimeout <= 0 {
+		healthCheckTimeout = defaultHealthCheckTimeout
+	}
+	health := f.NewHealthChecks(healthCheckTimeout)
+
+	metrics := adapters.NewPrometheusMetrics()
+	f.ProvideIn[f.Metrics](container, metrics)
+
 	initContext := f.InitContext{
 		InstanceId: instanceId,
 		Config:     cfg.config,
+		Container:  container,
+		Health:     health,
+		Metrics:    metrics,
 	}
 
 	log.Info("preloading features...")
@@ -147,13 +245,38 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 	var tenantProvider f.TenantProvider
 	var tokenProvider f.TokenProvider
 	var dataSource f.DataSource
+	var errorReporter f.ErrorReporter
+	var queueWorker f.QueueWorker
+	var pubSub f.PubSubProvider
+	var queueClient f.QueueClient
+	var multiTenantDS *adapters.MultiTenantDataSource
+
+	if !funk.IsEmpty(cfg.errorReporter) {
+		adapter, err := adapters.NewErrorReporter(cfg.errorReporter, cfg.envName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize error reporter: %v", err)
+		}
+		errorReporter = adapter
+	} else if !funk.IsEmpty(cfg.routerConfig.SentryDSN) {
+		errorReporter = adapters.NewSentryErrorReporter(cfg.routerConfig.SentryDSN, cfg.envName)
+	}
+	if errorReporter != nil {
+		f.ProvideIn(container, errorReporter)
+	}
+	if !funk.IsEmpty(cfg.tracingExporter) {
+		tracing, err := adapters.NewTracing(cfg.tracingExporter, appInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize tracing: %v", err)
+		}
+		f.ProvideIn(container, tracing)
+	}
 
 	if !funk.IsEmpty(cfg.i18n) {
 		adapter, err := adapters.NewLocalizer(cfg.i18n.LocaleFS, cfg.i18n.Locales)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize localizer: %v", err)
 		}
-		f.Provide(adapter)
+		f.ProvideIn(container, adapter)
 	}
 	if !funk.IsEmpty(cfg.tenantProvider) {
 		adapter, err := adapters.NewTenantProvider(cfg.tenantProvider)
@@ -161,76 +284,98 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 			return nil, fmt.Errorf("failed to initialize tenant provider: %v", err)
 		}
 		tenantProvider = adapter
-		f.Provide(tenantProvider)
+		f.ProvideIn(container, tenantProvider)
 	} else {
-		adapter := f.Lookup[f.TenantProvider]()
+		adapter := f.LookupIn[f.TenantProvider](container)
 		if adapter != nil {
 			tenantProvider = *adapter
 		}
 	}
 	if cfg.dsConfig != nil {
-		adapter := adapters.NewMultiTenantDS(cfg.dsConfig...)
+		adapter := adapters.NewMultiTenantDS(cfg.dsConfig...).UseMetrics(metrics)
 		if tenantProvider != nil {
 			adapter.UseTenantProvider(tenantProvider)
 		}
+		if cfg.outbox {
+			adapter.UseOutbox()
+		}
 		if err := adapter.Init(features); err != nil {
 			return nil, fmt.Errorf("[000] failed to initialize wMultiTenantDS: %v", err)
 		}
 		// ds = adapter
 		dataSource = adapter
-		f.Provide[f.DataSource](adapter)
-		f.Provide(adapters.NewEntityManagerImpl(adapter))
+		multiTenantDS = adapter
+		health.AddPing("datasource", adapter.Ping)
+		health.Add("tenants", adapter.CheckTenants)
+		health.Add("replicas", adapter.CheckReplicas)
+		f.ProvideIn[f.DataSource](container, adapter)
+		f.ProvideIn(container, adapters.NewEntityManagerImpl(adapter))
 	}
 	if !funk.IsEmpty(cfg.emailSender) {
 		adapter, err := adapters.NewEmailSender(cfg.appName, cfg.emailSender)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize email sender: %v", err)
 		}
-		f.Provide(adapter)
+		f.ProvideIn(container, adapter)
 	}
 	if !funk.IsEmpty(cfg.pubSubProvider) {
 		adapter, err := adapters.NewPubSubProvider(cfg.pubSubProvider)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize pubsub provider: %v", err)
 		}
+		adapters.Instrument(metrics, adapter)
 		if err := adapter.Init(); err != nil {
 			return nil, fmt.Errorf("failed to initialize pubsub provider: %v", err)
 		}
-		f.Provide(adapter)
+		health.AddPing("pubsub", adapter.Ping)
+		pubSub = adapter
+		f.ProvideIn(container, adapter)
 	}
 	if !funk.IsEmpty(cfg.cacheProvider) {
 		adapter, err := adapters.NewCacheProvider(cfg.cacheProvider)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize cache provider: %v", err)
 		}
+		adapters.Instrument(metrics, adapter)
 		if err := adapter.Init(); err != nil {
 			return nil, fmt.Errorf("failed to initialize cache provider: %v", err)
 		}
-		f.Provide(adapter)
+		health.AddPing("cache", adapter.Ping)
+		f.ProvideIn(container, adapter)
 
 		idempotencyStore := adapters.NewIdempotencyStore(adapter, 1*time.Hour)
-		f.Provide(idempotencyStore)
+		f.ProvideIn(container, idempotencyStore)
 	}
 	if !funk.IsEmpty(cfg.queueProvider) {
 		adapter, err := adapters.NewAsynqQueueProvider(cfg.queueProvider)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize queue provider: %v", err)
 		}
-		f.Provide(adapter)
+		adapter.UseMetrics(metrics)
+		health.AddPing("queue", adapter.Ping)
+		queueClient = adapter
+		f.ProvideIn(container, adapter)
+	}
+	if !funk.IsEmpty(cfg.queueWorker) {
+		adapter, err := adapters.NewAsynqWorker(cfg.queueWorker, errorReporter, shutdownTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize queue worker: %v", err)
+		}
+		adapter.UseMetrics(metrics)
+		queueWorker = adapter
+		health.AddPing("queue-worker", adapter.Ping)
+		f.ProvideIn(container, queueWorker)
 	}
 	if cfg.secretProvider != nil {
 		if err := cfg.secretProvider.Init(); err != nil {
 			return nil, fmt.Errorf("failed to initialize secret provider: %v", err)
 		}
-		f.Provide(cfg.secretProvider)
+		health.AddPing("secrets", cfg.secretProvider.Ping)
+		f.ProvideIn(container, cfg.secretProvider)
 		log.Info("secret provider initialized and registered")
 	} else {
 		log.Debug("no secret provider provided")
 	}
-	if !funk.IsEmpty(cfg.errorReporter) {
-		adapter := adapters.NewSentryErrorReporter(cfg.errorReporter, cfg.envName)
-		f.Provide(adapter)
-	}
 	if !funk.IsEmpty(cfg.tokenProvider) {
 		adapter, err := adapters.NewTokenProvider(f.JwtConfig{
 			Issuer:           cfg.appName,
@@ -242,23 +387,42 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 			return nil, fmt.Errorf("failed to initialize token provider: %v", err)
 		}
 		tokenProvider = adapter
-		f.Provide(tokenProvider)
+		f.ProvideIn(container, tokenProvider)
+	}
+
+	if cfg.outbox {
+		if multiTenantDS == nil {
+			return nil, fmt.Errorf("the outbox requires a data source")
+		}
+		f.ProvideIn(container, adapters.NewOutbox())
+		f.ProvideIn(container, adapters.NewOutboxRelay(multiTenantDS, adapters.OutboxRelayConfig{
+			PubSub:   pubSub,
+			Queue:    queueClient,
+			Reporter: errorReporter,
+		}))
 	}
 
-	f.Provide(adapters.NewCsrfTokenProvider())
-	f.Provide(appInfo)
+	f.ProvideIn(container, adapters.NewCsrfTokenProvider())
+	f.ProvideIn(container, appInfo)
 
 	router := adapters.NewEchoRouter(adapters.EchoRouterConfig{
 		Debug:          !production,
 		PublicFS:       cfg.routerConfig.PublicFS,
 		SessionSecret:  cfg.routerConfig.SessionSecret,
 		AllowOrigins:   cfg.routerConfig.AllowOrigins,
-		SentryDSN:      cfg.routerConfig.SentryDSN,
 		Env:            cfg.envName,
+		ErrorReporter:  errorReporter,
+		Container:      container,
+		HealthChecks:   health,
+		Metrics:        metrics,
 		TokenProvider:  tokenProvider,
 		TenantProvider: tenantProvider,
 		DataSource:     dataSource,
 		AuthProvider:   cfg.authProvider,
+		AppInfo:        appInfo,
+
+		LegacyErrorFormat: cfg.routerConfig.LegacyErrorFormat,
+		TxRetries:         cfg.routerConfig.TxRetries,
 	})
 
 	router.Init()
@@ -266,6 +430,7 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 	mcp := adapters.NewMCPServer(f.MCPServerConfig{
 		ToolsCapabilities:   true,
 		PromptsCapabilities: false,
+		ErrorReporter:       errorReporter,
 	})
 
 	mcp.Init(appInfo)
@@ -275,7 +440,9 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 
 	log.Info("initializing features...")
 	for _, feature := range features {
-		feature.OnInit(initContext)
+		featureContext := initContext
+		featureContext.Router = f.ForFeature(router, feature.Name)
+		feature.OnInit(featureContext)
 		log.Info("feature %s initialized", feature.Name)
 	}
 
@@ -283,9 +450,20 @@ func (app AppBuilder) Init(features []f.Feature) (f.App, error) {
 		router.MCP("/mcp", mcp.HttpHandler())
 	}
 
+	log.Info("starting components...")
+	if err := container.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to start components: %v", err)
+	}
+	router.SetReady(true)
+
 	return &appImpl{
-		router:     router,
-		instanceId: instanceId,
+		router:          router,
+		instanceId:      instanceId,
+		worker:          queueWorker,
+		reporter:        errorReporter,
+		container:       container,
+		port:            cfg.port,
+		shutdownTimeout: shutdownTimeout,
 	}, nil
 }
 
@@ -353,16 +531,70 @@ func (app AppBuilder) WithQueueProvider(provider string) AppBuilder {
 	return app
 }
 
+// WithPort sets the port used by App.Run, defaults to 8080
+func (app AppBuilder) WithPort(port int) AppBuilder {
+	app.config.port = port
+	return app
+}
+
+// WithShutdownTimeout bounds how long shutdown waits for in-flight requests and
+// queue jobs, defaults to 30s
+func (app AppBuilder) WithShutdownTimeout(timeout time.Duration) AppBuilder {
+	app.config.shutdownTimeout = timeout
+	return app
+}
+
+// WithHealthCheckTimeout bounds each check run by the /readyz endpoint, defaults to 2s
+func (app AppBuilder) WithHealthCheckTimeout(timeout time.Duration) AppBuilder {
+	app.config.healthCheckTimeout = timeout
+	return app
+}
+
+// WithContainer registers the application components in container instead of a
+// container of its own. Either way the container becomes the default one, used by the
+// package level functions such as f.Lookup.
+func (app AppBuilder) WithContainer(container *f.Container) AppBuilder {
+	app.config.container = container
+	return app
+}
+
+// WithQueueWorker processes jobs from the given redis url. Features register
+// their handlers on the f.QueueWorker found in the registry.
+func (app AppBuilder) WithQueueWorker(provider string) AppBuilder {
+	app.config.queueWorker = provider
+	return app
+}
+
 func (app AppBuilder) WithLogLevel(level string) AppBuilder {
 	app.config.logLevel = level
 	return app
 }
 
+// WithLogFormat selects the log output: log.FormatJSON, log.FormatPretty or log.FormatText.
+// Defaults to json in production and pretty otherwise.
+func (app AppBuilder) WithLogFormat(format string) AppBuilder {
+	app.config.logFormat = format
+	return app
+}
+
 func (app AppBuilder) WithErrorReporter(provider string) AppBuilder {
 	app.config.errorReporter = provider
 	return app
 }
 
+// WithOutbox creates an outbox table on every connection and relays the messages
+// written with f.Outbox once their transaction is committed.
+func (app AppBuilder) WithOutbox() AppBuilder {
+	app.config.outbox = true
+	return app
+}
+
+// WithTracing exports OpenTelemetry spans, see adapters.NewTracing for the supported exporters
+func (app AppBuilder) WithTracing(exporter string) AppBuilder {
+	app.config.tracingExporter = exporter
+	return app
+}
+
 func (app AppBuilder) WithTokenProvider(config f.JwtConfig) AppBuilder {
 	app.config.tokenProvider = &config
 	return app
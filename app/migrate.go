@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	adapters "github.com/soffa-projects/foundation-go/adapters"
+	f "github.com/soffa-projects/foundation-go/core"
+	"github.com/soffa-projects/foundation-go/log"
+	"github.com/thoas/go-funk"
+)
+
+// Migrate runs the migration command described by args (see f.ParseMigrationCommand)
+// against the default and tenant databases of the data source, without initializing
+// the application, and writes the report of every database to out:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+//		if err := builder.Migrate(ctx, features, os.Args[2:], os.Stdout); err != nil {
+//			os.Exit(1)
+//		}
+//		return
+//	}
+func (app AppBuilder) Migrate(ctx context.Context, features []f.Feature, args []string, out io.Writer) error {
+	log.Init(app.config.logLevel)
+	cmd, err := f.ParseMigrationCommand(args)
+	if err != nil {
+		return err
+	}
+	migrator, err := app.migrator(features)
+	if err != nil {
+		return err
+	}
+	reports, err := migrator.Migrate(ctx, cmd)
+	writeMigrationReports(out, reports)
+	return err
+}
+
+func (app AppBuilder) migrator(features []f.Feature) (f.Migrator, error) {
+	cfg := app.config
+	if cfg.dsConfig == nil {
+		return nil, fmt.Errorf("no data source configured")
+	}
+	features, err := checkFeatures(features...)
+	if err != nil {
+		return nil, err
+	}
+	ds := adapters.NewMultiTenantDS(cfg.dsConfig...)
+	if !funk.IsEmpty(cfg.tenantProvider) {
+		tenantProvider, err := adapters.NewTenantProvider(cfg.tenantProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize tenant provider: %v", err)
+		}
+		ds.UseTenantProvider(tenantProvider)
+	} else if cfg.container != nil {
+		if tenantProvider := f.LookupIn[f.TenantProvider](cfg.container); tenantProvider != nil {
+			ds.UseTenantProvider(*tenantProvider)
+		}
+	}
+	return adapters.NewMigrator(ds, features), nil
+}
+
+func writeMigrationReports(out io.Writer, reports []f.MigrationReport) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	for _, report := range reports {
+		fmt.Fprintf(w, "== %s (version %d)\n", report.Tenant, report.Version)
+		for _, migration := range report.Migrations {
+			state, appliedAt := "pending", ""
+			if migration.Applied {
+				state, appliedAt = "applied", migration.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%d\t%s\n", state, appliedAt, migration.Version, migration.Name)
+		}
+		for _, step := range report.Steps {
+			duration := ""
+			if step.Duration > 0 {
+				duration = step.Duration.Round(time.Millisecond).String()
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%s\n", step.Direction, step.Version, step.Name, duration)
+			if step.SQL != "" {
+				// flush so the SQL does not break the alignment of the table
+				w.Flush()
+				fmt.Fprintf(out, "    %s\n", strings.ReplaceAll(step.SQL, "\n", "\n    "))
+			}
+		}
+		if len(report.Migrations) == 0 && len(report.Steps) == 0 && report.Err == nil {
+			fmt.Fprintln(w, "  nothing to do")
+		}
+		if report.Err != nil {
+			fmt.Fprintf(w, "  FAILED: %v\n", report.Err)
+		}
+	}
+}
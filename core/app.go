@@ -12,9 +12,12 @@ const (
 
 type App interface {
 	Start(port int) error
+	// Run serves until ctx is cancelled or SIGINT/SIGTERM is received, then shuts down gracefully
+	Run(ctx context.Context) error
 	Shutdown(ctx context.Context)
 	Router() Router
 	InstanceId() string
+	Container() *Container
 }
 
 type AppInfo struct {
@@ -27,6 +30,7 @@ type AppConfig = any
 
 type HttpRouter interface {
 	Group(path string, middlewares ...Middleware) HttpRouter
+	Handle(route Route)
 	GET(path string, handler func(c HttpContext) error, middlewares ...Middleware)
 	POST(path string, handler func(c HttpContext) error, middlewares ...Middleware)
 	DELETE(path string, handler func(c HttpContext) error, middlewares ...Middleware)
@@ -48,8 +52,10 @@ type MCP struct {
 }
 
 type Feature struct {
-	Name       string
-	FS         fs.FS
+	Name string
+	FS   fs.FS
+	// Migrations are the Go migrations of the feature, run with the SQL ones of FS
+	Migrations []GoMigration
 	DependsOn  []Feature
 	OnInit     func(c InitContext)
 	BeforeInit func(c InitContext)
@@ -60,6 +66,11 @@ type InitContext struct {
 	Config     AppConfig
 	Router     HttpRouter
 	MCP        McpRouter
+	Container  *Container
+	// Health collects the checks reported by the readiness endpoint
+	Health *HealthChecks
+	// Metrics registers custom counters, gauges and histograms exposed on /metrics
+	Metrics Metrics
 }
 
 /*
@@ -84,7 +95,11 @@ type Context interface {
 	AuthToken() string
 	IdemPotencyKey() string
 	SetTenant(tenantId string)
+	// PinPrimary routes the reads of the rest of the request to the primary database
+	PinPrimary()
 	RemoteAddr() string
+	// Container returns the components of the application serving the request
+	Container() *Container
 }
 
 type HttpContext interface {
@@ -94,8 +109,13 @@ type HttpContext interface {
 	Param(value string) string
 	QueryParam(value string) string
 	Header(value string) string
+	// SetHeader sets a response header
+	SetHeader(key string, value string)
 	Host() string
+	// RequestURI returns the path and query of the request
+	RequestURI() string
 	Bind(value any) error
+	ShouldBind(value any) error
 	//
 	JSON(status int, data any) error
 	Redirect(status int, url string) error
@@ -2,6 +2,7 @@ package f
 
 import (
 	"context"
+	"database/sql"
 )
 
 type Entity any
@@ -10,22 +11,67 @@ type DefaultCnxKey struct{}
 type TransactionalKey struct{}
 type TenantKey struct{}
 type AuthenticationKey struct{}
+type PrimaryKey struct{}
 
 type QueryOpts struct {
 	Columns string
 	Joins   []string
+	// Where and OrderBy are raw SQL, prefer Filter and Sort for values coming from clients
 	Where   string
 	OrderBy string
 	Args    []any
+	Filter  Condition
+	Sort    []Sort
 	Limit   int
 	Offset  int
+	// WithDeleted includes the soft deleted rows
+	WithDeleted bool
+}
+
+type TxMode int
+
+const (
+	// ReadWriteTx runs the handler in a read-write transaction, committed unless it panics
+	ReadWriteTx TxMode = iota
+	// ReadOnlyTx runs the handler in a read-only transaction, rejecting its writes where the database supports it
+	ReadOnlyTx
+	// NoTx runs the handler on the connections themselves, their reads going to replicas
+	NoTx
+)
+
+// TxOptions configures the transactions of a route, opened on the first query
+type TxOptions struct {
+	Mode TxMode
+	// Isolation level of the transactions, the default of the database when zero
+	Isolation sql.IsolationLevel
+	// Retries re-runs the handler when postgres aborts its transaction on a serialization
+	// failure or a deadlock, RouterConfig.TxRetries when zero and never when negative.
+	// The response is held until the transaction commits.
+	Retries int
+}
+
+// PinPrimary routes the reads made with the returned context to the primary database
+// rather than a replica, to read the writes that were just committed.
+func PinPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, PrimaryKey{}, true)
+}
+
+// PinnedToPrimary reports whether ctx was pinned to the primary database by PinPrimary
+func PinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(PrimaryKey{}).(bool)
+	return pinned
 }
 
 type Connection interface {
 	DatabaseUrl() string
 	//
 	SetSchema(schema string) error
+	// Tx begins a transaction, a savepoint when the connection is already a transaction
 	Tx(ctx context.Context) (Connection, error)
+	// Transaction runs fn in a transaction of the connection, committed when fn returns nil
+	// and rolled back otherwise. Nested calls use savepoints, so fn may run within the
+	// transaction of a request or of another Transaction.
+	Transaction(ctx context.Context, fn func(tx Connection) error) error
 	Commit() error
 	Rollback() error
 	Ping() error
@@ -37,88 +83,17 @@ type Connection interface {
 	FindByJoin(ctx context.Context, model Entity, join string, where string, args ...any) (bool, error)
 	CountByJoin(ctx context.Context, model Entity, join string, where string, args ...any) (int, error)
 	Query(ctx context.Context, model Entity, opts ...QueryOpts) (bool, error)
+	// Paginate scans one page of rows into models, a pointer to a slice
+	Paginate(ctx context.Context, models Entity, req PageRequest, opts ...QueryOpts) (PageInfo, error)
 	Insert(ctx context.Context, model Entity) error
 	InsertBatch(ctx context.Context, models Entity) error
+	// Upsert inserts model or updates the row conflicting on conflictColumns, the primary key by default.
+	// MySQL ignores conflictColumns and updates the row conflicting on any unique key.
+	// The row of a Versioned model is only updated at the version of model, see Update.
+	Upsert(ctx context.Context, model Entity, conflictColumns ...string) error
 	Update(ctx context.Context, model Entity, columns ...string) error
+	// UpdateBy updates the rows matching where, the rows of a Versioned entity only at its version
 	UpdateBy(ctx context.Context, entity Entity, columns []string, where string, args ...any) (int64, error)
 	Delete(ctx context.Context, model Entity) error
 	DeleteBy(ctx context.Context, model Entity, where string, args ...any) error
 }
-
-// ------------------------------------------------------------------------------------------------------------------
-// TX IMPL
-// ------------------------------------------------------------------------------------------------------------------
-
-/*
-func ds(ctx context.Context, defaultTenant bool) Connection {
-	var value any
-	if defaultTenant {
-		value = ctx.Value(DefaultCnxKey{})
-	} else {
-		value = ctx.Value(TenantCnxKey{})
-	}
-	if value == nil {
-		log.Error("MISSING_DS_IN_CONTEXT_CHECK_TENANT_MIDDLEWARE")
-		panic("MISSING_DS_IN_CONTEXT_CHECK_TENANT_MIDDLEWARE")
-	}
-	return value.(Connection)
-}
-
-func (r *Repo) Insert(ctx context.Context, entity Entity) error {
-	return ds(ctx, r.DefaultTenant).Insert(ctx, entity)
-}
-
-func (r *Repo) InsertBatch(ctx context.Context, records Entity) error {
-	if err := ds(ctx, r.DefaultTenant).InsertBatch(ctx, records); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *Repo) Update(ctx context.Context, entity ...any) error {
-	for _, record := range entity {
-		if err := ds(ctx, r.DefaultTenant).Update(ctx, record); err != nil {
-			return err
-		}
-	}
-	return nil
-
-}
-
-func (r *Repo) UpdateColumns(ctx context.Context, entity Entity, columns ...string) error {
-	return ds(ctx, r.DefaultTenant).Update(ctx, entity, columns...)
-}
-
-func (r *Repo) Delete(ctx context.Context, entity ...Entity) error {
-	for _, record := range entity {
-		if err := ds(ctx, r.DefaultTenant).Delete(ctx, record); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (r *Repo) DeleteById(ctx context.Context, model Entity, id string) error {
-	return ds(ctx, r.DefaultTenant).DeleteBy(ctx, model, "id=?", id)
-}
-
-func (r *Repo) FindBy(ctx context.Context, model Entity, where string, args ...any) (bool, error) {
-	return ds(ctx, r.DefaultTenant).FindBy(ctx, model, where, args...)
-}
-
-func (r *Repo) ExistsBy(ctx context.Context, model Entity, where string, args ...any) (bool, error) {
-	return ds(ctx, r.DefaultTenant).ExistsBy(ctx, model, where, args...)
-}
-
-func (r *Repo) CountAll(ctx context.Context, model Entity) (int, error) {
-	return ds(ctx, r.DefaultTenant).Count(ctx, model)
-}
-
-func (r *Repo) CountBy(ctx context.Context, model Entity, where string, args ...any) (int, error) {
-	return ds(ctx, r.DefaultTenant).CountBy(ctx, model, where, args...)
-}
-
-func (r *Repo) Query(ctx context.Context, model Entity, opts QueryOpts) (bool, error) {
-	return ds(ctx, r.DefaultTenant).Query(ctx, model, opts)
-}
-*/
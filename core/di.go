@@ -1,87 +1,480 @@
 package f
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	registry = make(map[reflect.Type]any)
-	cache    = make(map[reflect.Type]any) // resolved singletons
-	mu       sync.RWMutex
+type Scope int
+
+const (
+	// SingletonScope components are built once and shared by the whole application
+	SingletonScope Scope = iota
+	// RequestScope components are built once per request scope, see NewRequestScope
+	RequestScope
 )
 
+// Starter is implemented by components that need to run code when the application starts
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by components that need to release resources on shutdown.
+// Components implementing io.Closer are closed as well.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+type component struct {
+	container *Container
+	t         reflect.Type
+	scope     Scope
+	deps      []reflect.Type
+	factory   func(ctx context.Context) (any, error)
+	index     int
+
+	mu    sync.Mutex
+	value any
+	built bool
+}
+
+// Container holds the components of an application. Each app owns one so that
+// several apps (e.g. parallel tests) can live in the same process. The package level
+// functions are a facade over the default container, the container of the last app
+// initialized: code running in parallel apps resolves from c.Container() or the
+// container of its context instead.
+type Container struct {
+	mu       sync.RWMutex
+	registry map[reflect.Type]*component
+	cache    map[reflect.Type]any // resolved singletons
+	built    []any                // singletons in the order they were built
+	started  map[reflect.Type]bool
+}
+
+type containerKey struct{}
+
+var defaultContainer atomic.Pointer[Container]
+
+func init() {
+	defaultContainer.Store(NewContainer())
+}
+
+func NewContainer() *Container {
+	return &Container{
+		registry: make(map[reflect.Type]*component),
+		cache:    make(map[reflect.Type]any),
+		started:  make(map[reflect.Type]bool),
+	}
+}
+
+// DefaultContainer returns the container used by Provide, Lookup and Resolve
+func DefaultContainer() *Container {
+	return defaultContainer.Load()
+}
+
+// SetDefaultContainer makes container the one used by Provide, Lookup and Resolve, apps
+// set theirs on Init
+func SetDefaultContainer(container *Container) {
+	defaultContainer.Store(container)
+}
+
+// WithContainer attaches container to ctx, see ContainerFrom
+func WithContainer(ctx context.Context, container *Container) context.Context {
+	return context.WithValue(ctx, containerKey{}, container)
+}
+
+// ContainerFrom returns the container attached to ctx, or the default container
+func ContainerFrom(ctx context.Context) *Container {
+	if ctx != nil {
+		if container, ok := ctx.Value(containerKey{}).(*Container); ok && container != nil {
+			return container
+		}
+	}
+	return DefaultContainer()
+}
+
+// TypeOf returns the registry key of T, it is used to declare factory dependencies
+func TypeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem() // reflect type for interface or struct
+}
+
 // Provide registers a provider instance for type T
 func Provide[T any](provider T) {
-	t := reflect.TypeOf((*T)(nil)).Elem() // reflect type for interface or struct
-	mu.Lock()
-	defer mu.Unlock()
-	registry[t] = provider
+	ProvideIn(DefaultContainer(), provider)
+}
+
+// ProvideIn registers a provider instance for type T in container
+func ProvideIn[T any](container *Container, provider T) {
+	t := TypeOf[T]()
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	container.registry[t] = &component{container: container, t: t, value: provider, built: true, index: len(container.registry)}
+	delete(container.cache, t)
+	container.built = append(container.built, provider)
 	log.Infof("[di] component registered %s", t.String())
 }
 
+// ProvideFactory registers a singleton built on first use. deps lists the components
+// the factory resolves, they are started before and stopped after this one.
+func ProvideFactory[T any](factory func() (T, error), deps ...reflect.Type) {
+	ProvideFactoryIn(DefaultContainer(), factory, deps...)
+}
+
+func ProvideFactoryIn[T any](container *Container, factory func() (T, error), deps ...reflect.Type) {
+	register[T](container, SingletonScope, func(ctx context.Context) (any, error) {
+		return factory()
+	}, deps)
+}
+
+// ProvideScoped registers a component built once per request scope, use ResolveScoped
+// to retrieve it. Scoped instances are stopped when the scope is released.
+func ProvideScoped[T any](factory func(ctx context.Context) (T, error), deps ...reflect.Type) {
+	ProvideScopedIn(DefaultContainer(), factory, deps...)
+}
+
+func ProvideScopedIn[T any](container *Container, factory func(ctx context.Context) (T, error), deps ...reflect.Type) {
+	register[T](container, RequestScope, func(ctx context.Context) (any, error) {
+		return factory(ctx)
+	}, deps)
+}
+
+func register[T any](container *Container, scope Scope, factory func(ctx context.Context) (any, error), deps []reflect.Type) {
+	t := TypeOf[T]()
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	container.registry[t] = &component{container: container, t: t, scope: scope, deps: deps, factory: factory, index: len(container.registry)}
+	delete(container.cache, t)
+	log.Infof("[di] component factory registered %s", t.String())
+}
+
 type ResolveOpt struct {
 	Optional bool
 }
 
 // Resolve returns the component of type T
 func Lookup[T any]() *T {
-	t := reflect.TypeOf((*T)(nil)).Elem()
-
-	mu.RLock()
-	if c, ok := cache[t]; ok { // fast path (cached instance)
-		mu.RUnlock()
-		res := c.(T)
-		return &res
-	}
-	mu.RUnlock()
+	return LookupIn[T](DefaultContainer())
+}
 
-	mu.Lock()
-	defer mu.Unlock()
+// LookupIn returns the component of type T registered in container, or nil
+func LookupIn[T any](container *Container) *T {
+	t := TypeOf[T]()
 
-	// check cache again in case another goroutine populated it
-	if c, ok := cache[t]; ok {
+	container.mu.RLock()
+	if c, ok := container.cache[t]; ok { // fast path (cached instance)
+		container.mu.RUnlock()
 		res := c.(T)
 		return &res
 	}
+	container.mu.RUnlock()
 
-	if component, ok := registry[t]; ok {
-		cache[t] = component
-		res := component.(T)
-		return &res
+	value, err := container.resolve(context.Background(), t)
+	if err != nil {
+		if _, missing := err.(notRegisteredError); !missing {
+			log.Errorf("[di] %v", err)
+		}
+		return nil
 	}
-	return nil
+	res := value.(T)
+	return &res
 }
 
 // Resolve returns the component of type T, or error if not found
 // FIXED: Returns error instead of calling log.Fatal
 func Resolve[T any]() (T, error) {
-	res := Lookup[T]()
-	if res == nil {
-		var zero T
-		t := reflect.TypeOf((*T)(nil)).Elem().String()
-		return zero, fmt.Errorf("failed to resolve component %s", t)
+	return ResolveIn[T](DefaultContainer())
+}
+
+// ResolveIn returns the component of type T registered in container, or error if not found
+func ResolveIn[T any](container *Container) (T, error) {
+	return resolveAs[T](context.Background(), container)
+}
+
+// ResolveScoped returns the component of type T from the container attached to ctx.
+// Request scoped components are taken from the scope attached to ctx.
+func ResolveScoped[T any](ctx context.Context) (T, error) {
+	return resolveAs[T](ctx, ContainerFrom(ctx))
+}
+
+func resolveAs[T any](ctx context.Context, container *Container) (T, error) {
+	var zero T
+	value, err := container.resolve(ctx, TypeOf[T]())
+	if err != nil {
+		return zero, err
 	}
-	return *res, nil
+	return value.(T), nil
 }
 
 // MustResolve returns the component of type T, or panics if not found
 // Use this only in initialization code where panic is acceptable
 func MustResolve[T any]() T {
-	res, err := Resolve[T]()
+	return MustResolveIn[T](DefaultContainer())
+}
+
+func MustResolveIn[T any](container *Container) T {
+	res, err := ResolveIn[T](container)
 	if err != nil {
 		panic(err)
 	}
 	return res
 }
 
-// Clear wipes out all registrations and cache
+// Clear wipes out all registrations and cache of the default container
 func Clear() {
-	mu.Lock()
-	defer mu.Unlock()
-	registry = make(map[reflect.Type]any)
-	cache = make(map[reflect.Type]any)
+	DefaultContainer().Clear()
+}
+
+// Clear wipes out all registrations and cache
+func (c *Container) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registry = make(map[reflect.Type]*component)
+	c.cache = make(map[reflect.Type]any)
+	c.started = make(map[reflect.Type]bool)
+	c.built = nil
+}
+
+type notRegisteredError struct {
+	t reflect.Type
+}
+
+func (e notRegisteredError) Error() string {
+	return fmt.Sprintf("failed to resolve component %s", e.t.String())
+}
+
+func (c *Container) resolve(ctx context.Context, t reflect.Type) (any, error) {
+	c.mu.RLock()
+	comp, ok := c.registry[t]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, notRegisteredError{t: t}
+	}
+	if comp.scope == RequestScope {
+		scope, _ := ctx.Value(requestScopeKey{}).(*requestScope)
+		if scope == nil {
+			return nil, fmt.Errorf("failed to resolve component %s: no request scope", t.String())
+		}
+		return scope.get(ctx, comp)
+	}
+	return comp.instance()
+}
+
+// instance returns the singleton, building it on first use
+func (c *component) instance() (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	container := c.container
+	if !c.built {
+		value, err := c.factory(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to build component %s: %w", c.t.String(), err)
+		}
+		c.value, c.built = value, true
+		container.mu.Lock()
+		container.built = append(container.built, value)
+		container.mu.Unlock()
+		log.Debugf("[di] component built %s", c.t.String())
+	}
+	container.mu.Lock()
+	if container.registry[c.t] == c {
+		container.cache[c.t] = c.value
+	}
+	container.mu.Unlock()
+	return c.value, nil
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// LIFECYCLE
+// ------------------------------------------------------------------------------------------------------------------
+
+// StartComponents starts the components of the default container
+func StartComponents(ctx context.Context) error {
+	return DefaultContainer().Start(ctx)
+}
+
+// StopComponents stops the components of the default container
+func StopComponents(ctx context.Context) error {
+	return DefaultContainer().Stop(ctx)
+}
+
+// Start builds every singleton in dependency order and calls Start on those
+// implementing Starter.
+func (c *Container) Start(ctx context.Context) error {
+	ordered, err := c.orderComponents()
+	if err != nil {
+		return err
+	}
+	for _, comp := range ordered {
+		value, err := comp.instance()
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		alreadyStarted := c.started[comp.t]
+		c.started[comp.t] = true
+		c.mu.Unlock()
+		if starter, ok := value.(Starter); ok && !alreadyStarted {
+			if err := starter.Start(ctx); err != nil {
+				return fmt.Errorf("failed to start component %s: %w", comp.t.String(), err)
+			}
+			log.Infof("[di] component started %s", comp.t.String())
+		}
+	}
+	return nil
+}
+
+// Stop stops the built singletons in the reverse order they were built.
+// Every component is stopped even if another one fails, errors are joined.
+func (c *Container) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	components := c.built
+	c.built = nil
+	c.started = make(map[reflect.Type]bool)
+	c.mu.Unlock()
+	return stopAll(ctx, components)
+}
+
+func stopAll(ctx context.Context, components []any) error {
+	var errs []error
+	stopped := map[uintptr]bool{}
+	for i := len(components) - 1; i >= 0; i-- {
+		value := components[i]
+		// the same instance can be registered under several types
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer {
+			if stopped[rv.Pointer()] {
+				continue
+			}
+			stopped[rv.Pointer()] = true
+		}
+		if err := stop(ctx, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func stop(ctx context.Context, value any) error {
+	switch v := value.(type) {
+	case Stopper:
+		return v.Stop(ctx)
+	case io.Closer:
+		return v.Close()
+	}
+	return nil
+}
+
+// orderComponents sorts singletons so that dependencies come first, falling back to
+// registration order.
+func (c *Container) orderComponents() ([]*component, error) {
+	c.mu.RLock()
+	components := make([]*component, 0, len(c.registry))
+	for _, comp := range c.registry {
+		if comp.scope == SingletonScope {
+			components = append(components, comp)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].index < components[j].index
+	})
+
+	byType := make(map[reflect.Type]*component, len(components))
+	for _, comp := range components {
+		byType[comp.t] = comp
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[*component]int{}
+	ordered := make([]*component, 0, len(components))
+	var visit func(comp *component) error
+	visit = func(comp *component) error {
+		switch state[comp] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("cyclic dependency detected on component %s", comp.t.String())
+		}
+		state[comp] = visiting
+		for _, dep := range comp.deps {
+			if d, ok := byType[dep]; ok {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		state[comp] = visited
+		ordered = append(ordered, comp)
+		return nil
+	}
+	for _, comp := range components {
+		if err := visit(comp); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// REQUEST SCOPE
+// ------------------------------------------------------------------------------------------------------------------
+
+type requestScopeKey struct{}
+
+type requestScope struct {
+	mu     sync.Mutex
+	values map[reflect.Type]any
+	order  []any
+}
+
+// NewRequestScope attaches a new scope for request scoped components to ctx. The
+// returned function stops the instances built within the scope.
+func NewRequestScope(ctx context.Context) (context.Context, func()) {
+	scope := &requestScope{}
+	release := func() {
+		scope.mu.Lock()
+		values := scope.order
+		scope.values, scope.order = nil, nil
+		scope.mu.Unlock()
+		if err := stopAll(context.Background(), values); err != nil {
+			log.Errorf("[di] failed to release request scope: %v", err)
+		}
+	}
+	return context.WithValue(ctx, requestScopeKey{}, scope), release
+}
+
+func (s *requestScope) get(ctx context.Context, c *component) (any, error) {
+	s.mu.Lock()
+	if value, ok := s.values[c.t]; ok {
+		s.mu.Unlock()
+		return value, nil
+	}
+	s.mu.Unlock()
+
+	// the factory runs unlocked so it can resolve other scoped components
+	value, err := c.factory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build component %s: %w", c.t.String(), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.values[c.t]; ok {
+		return existing, nil
+	}
+	if s.values == nil {
+		s.values = make(map[reflect.Type]any)
+	}
+	s.values[c.t] = value
+	s.order = append(s.order, value)
+	return value, nil
 }
@@ -1,6 +1,8 @@
 package f
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/go-playground/assert/v2"
@@ -326,3 +328,159 @@ func TestResolve_ErrorMessage(t *testing.T) {
 	// Error message should contain the type name
 	assert.Equal(t, err.Error(), "failed to resolve component f.TestService")
 }
+
+// Lifecycle test components recording start/stop events
+type lifecycleComponent struct {
+	name   string
+	events *[]string
+}
+
+func (c *lifecycleComponent) Start(ctx context.Context) error {
+	*c.events = append(*c.events, "start:"+c.name)
+	return nil
+}
+
+func (c *lifecycleComponent) Stop(ctx context.Context) error {
+	*c.events = append(*c.events, "stop:"+c.name)
+	return nil
+}
+
+type closerComponent struct {
+	closed bool
+}
+
+func (c *closerComponent) Close() error {
+	c.closed = true
+	return nil
+}
+
+type repository struct{ *lifecycleComponent }
+type database struct{ *lifecycleComponent }
+
+func TestProvideFactory_Lazy(t *testing.T) {
+	Clear()
+
+	calls := 0
+	ProvideFactory(func() (TestService, error) {
+		calls++
+		return &testServiceImpl{name: "lazy"}, nil
+	})
+	assert.Equal(t, calls, 0)
+
+	assert.Equal(t, MustResolve[TestService]().GetName(), "lazy")
+	assert.Equal(t, MustResolve[TestService]().GetName(), "lazy")
+	assert.Equal(t, calls, 1)
+}
+
+func TestProvideFactory_Error(t *testing.T) {
+	Clear()
+
+	ProvideFactory(func() (TestService, error) {
+		return nil, errors.New("boom")
+	})
+	_, err := Resolve[TestService]()
+	assert.NotEqual(t, err, nil)
+	assert.Equal(t, Lookup[TestService](), nil)
+}
+
+func TestStartStopComponents_DependencyOrder(t *testing.T) {
+	Clear()
+
+	var events []string
+	// registered before its dependency on purpose
+	ProvideFactory(func() (*repository, error) {
+		MustResolve[*database]()
+		return &repository{&lifecycleComponent{name: "repository", events: &events}}, nil
+	}, TypeOf[*database]())
+	ProvideFactory(func() (*database, error) {
+		return &database{&lifecycleComponent{name: "database", events: &events}}, nil
+	})
+	closer := &closerComponent{}
+	Provide(closer)
+
+	assert.Equal(t, StartComponents(context.Background()), nil)
+	assert.Equal(t, StopComponents(context.Background()), nil)
+
+	assert.Equal(t, events, []string{"start:database", "start:repository", "stop:repository", "stop:database"})
+	assert.Equal(t, closer.closed, true)
+
+	// stopping twice is a no-op
+	assert.Equal(t, StopComponents(context.Background()), nil)
+	assert.Equal(t, len(events), 4)
+}
+
+func TestStartComponents_Cycle(t *testing.T) {
+	Clear()
+
+	ProvideFactory(func() (*repository, error) {
+		return &repository{}, nil
+	}, TypeOf[*database]())
+	ProvideFactory(func() (*database, error) {
+		return &database{}, nil
+	}, TypeOf[*repository]())
+
+	err := StartComponents(context.Background())
+	assert.NotEqual(t, err, nil)
+}
+
+func TestProvideScoped(t *testing.T) {
+	Clear()
+
+	built := 0
+	ProvideScoped(func(ctx context.Context) (*closerComponent, error) {
+		built++
+		return &closerComponent{}, nil
+	})
+
+	_, err := Resolve[*closerComponent]()
+	assert.NotEqual(t, err, nil)
+
+	ctx, release := NewRequestScope(context.Background())
+	first, err := ResolveScoped[*closerComponent](ctx)
+	assert.Equal(t, err, nil)
+	second, _ := ResolveScoped[*closerComponent](ctx)
+	assert.Equal(t, first == second, true)
+
+	other, release2 := NewRequestScope(context.Background())
+	third, _ := ResolveScoped[*closerComponent](other)
+	assert.Equal(t, first == third, false)
+	assert.Equal(t, built, 2)
+
+	release()
+	assert.Equal(t, first.closed, true)
+	assert.Equal(t, third.closed, false)
+	release2()
+	assert.Equal(t, third.closed, true)
+}
+
+func TestContainer_Isolation(t *testing.T) {
+	t.Parallel()
+
+	first := NewContainer()
+	second := NewContainer()
+	ProvideIn[TestService](first, &testServiceImpl{name: "first"})
+	ProvideIn[TestService](second, &testServiceImpl{name: "second"})
+
+	assert.Equal(t, MustResolveIn[TestService](first).GetName(), "first")
+	assert.Equal(t, MustResolveIn[TestService](second).GetName(), "second")
+
+	first.Clear()
+	assert.Equal(t, LookupIn[TestService](first), nil)
+	assert.Equal(t, (*LookupIn[TestService](second)).GetName(), "second")
+}
+
+func TestContainerFrom(t *testing.T) {
+	t.Parallel()
+
+	container := NewContainer()
+	ProvideIn[AnotherService](container, &anotherServiceImpl{value: 7})
+
+	assert.Equal(t, ContainerFrom(context.Background()) == DefaultContainer(), true)
+
+	ctx := WithContainer(context.Background(), container)
+	assert.Equal(t, ContainerFrom(ctx) == container, true)
+
+	service, err := ResolveScoped[AnotherService](ctx)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, service.GetValue(), 7)
+}
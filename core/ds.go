@@ -2,9 +2,25 @@ package f
 
 import (
 	"context"
+	stderrors "errors"
 	"io/fs"
+	"time"
 )
 
+const (
+	// SchemaStrategy stores every tenant in its own postgres schema of the default database,
+	// or its own database of the default mysql server
+	SchemaStrategy = "schema"
+	// DiscriminatorStrategy stores the tenants in the tables of the default database, the
+	// rows of TenantOwned entities being scoped by their tenant_id column
+	DiscriminatorStrategy = "discriminator"
+)
+
+// ErrNoTenant is the cause of the errors of the queries on TenantOwned entities that run
+// without a tenant in their context with the discriminator strategy, they render as a 500.
+// Test it with errors.Is.
+var ErrNoTenant = stderrors.New("no tenant in context to scope the query")
+
 type DataSource interface {
 	Init(features []Feature) error
 	DefaultConnection() Connection
@@ -17,6 +33,8 @@ type Tenant struct {
 	Name        string `json:"name,omitempty"`
 	AltID       string `json:"alt_id,omitempty"`
 	DatabaseUrl string `json:"database_url,omitempty"`
+	// ReplicaUrls are read replicas of DatabaseUrl
+	ReplicaUrls []string `json:"replica_urls,omitempty"`
 }
 
 type TenantList struct {
@@ -35,16 +53,41 @@ type TenantAlreadyExistsError struct {
 }
 
 type DataSourceConfig struct {
-	DatabaseUrl    string
-	Prefix         string
+	DatabaseUrl string
+	// ReplicaUrls are read replicas of DatabaseUrl, the reads outside transactions are
+	// spread over the healthy ones
+	ReplicaUrls []string
+	// MaxReplicaLag sets aside the replicas lagging further behind, no limit when zero
+	MaxReplicaLag time.Duration
+	Prefix        string
+	// Strategy isolates the tenants: SchemaStrategy, DiscriminatorStrategy, or their own
+	// DatabaseUrl when empty
 	Strategy       string
 	MigrationFS    fs.FS
 	TenantProvider TenantProvider
+	// Concurrency is the number of tenants initialized in parallel, 8 by default
+	Concurrency int
+	// TenantTimeout bounds the connection and migration of a tenant, 2 minutes by default
+	TenantTimeout time.Duration
+	// ReloadInterval reloads the tenants from the provider periodically when set
+	ReloadInterval time.Duration
+	// RowLevelSecurity guards the TenantOwned tables of the discriminator strategy with
+	// postgres row level security policies on top of the filters added to the queries.
+	// The statements run outside of a transaction get their own to set the tenant.
+	RowLevelSecurity bool
+}
+
+// TenantAvailability is implemented by data sources quarantining the tenants that fail
+// to initialize instead of failing altogether.
+type TenantAvailability interface {
+	// Unavailable returns why tenantId is quarantined, nil when it is available
+	Unavailable(tenantId string) error
 }
 
 type ConnectionConfig struct {
 	Id          string
 	DatabaseUrl string
+	ReplicaUrls []string
 }
 
 type EntityManager interface {
@@ -0,0 +1,98 @@
+package f
+
+import (
+	"context"
+	"time"
+)
+
+// Entity behaviors are opted in by embedding the marker structs below:
+//
+//	type Invoice struct {
+//		f.Entity `bun:"table:invoices"`
+//		Id       string `bun:",pk"`
+//		f.Timestamps
+//		f.Audit
+//		f.SoftDelete
+//		f.Versioned
+//		f.TenantOwned
+//	}
+
+// Timestamps maintains the created_at and updated_at columns
+type Timestamps struct {
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"createdAt"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updatedAt"`
+}
+
+// Audit maintains the created_by and updated_by columns with the authenticated user
+type Audit struct {
+	CreatedBy string `bun:",nullzero" json:"createdBy,omitempty"`
+	UpdatedBy string `bun:",nullzero" json:"updatedBy,omitempty"`
+}
+
+// SoftDelete turns deletes into setting deleted_at, deleted rows are then ignored by
+// queries unless QueryOpts.WithDeleted is set.
+type SoftDelete struct {
+	DeletedAt time.Time `bun:",soft_delete,nullzero" json:"deletedAt,omitempty"`
+}
+
+// Versioned enables optimistic locking: updates of a stale version fail with a Conflict error
+type Versioned struct {
+	Version int64 `bun:",notnull,default:1" json:"version"`
+}
+
+// TenantOwned scopes the rows of an entity to the tenant of the context with the
+// discriminator strategy: queries are filtered on tenant_id and inserts stamp it.
+type TenantOwned struct {
+	TenantId string `bun:",notnull" json:"-"`
+}
+
+type TimestampedEntity interface {
+	SetTimestamps(now time.Time, created bool)
+}
+
+type AuditedEntity interface {
+	SetAuditUser(userId string, created bool)
+}
+
+type TenantOwnedEntity interface {
+	SetTenantId(tenantId string)
+}
+
+type VersionedEntity interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+func (t *Timestamps) SetTimestamps(now time.Time, created bool) {
+	if created && t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+}
+
+func (a *Audit) SetAuditUser(userId string, created bool) {
+	if created && a.CreatedBy == "" {
+		a.CreatedBy = userId
+	}
+	a.UpdatedBy = userId
+}
+
+func (v *Versioned) GetVersion() int64 {
+	return v.Version
+}
+
+func (v *Versioned) SetVersion(version int64) {
+	v.Version = version
+}
+
+func (t *TenantOwned) SetTenantId(tenantId string) {
+	t.TenantId = tenantId
+}
+
+// ActingUser returns the id of the user authenticated in ctx, if any
+func ActingUser(ctx context.Context) string {
+	if auth, ok := ctx.Value(AuthenticationKey{}).(*Authentication); ok && auth != nil {
+		return auth.UserId
+	}
+	return ""
+}
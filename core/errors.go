@@ -1,4 +1,74 @@
 package f
 
+import (
+	"context"
+	"time"
+)
+
+type RequestIdKey struct{}
+type RouteKey struct{}
+
 type ErrorReporter interface {
+	// CaptureError reports an error along with the scope found in ctx
+	CaptureError(ctx context.Context, err error)
+	// CapturePanic reports a recovered panic value along with the scope found in ctx
+	CapturePanic(ctx context.Context, value any)
+	AddBreadcrumb(ctx context.Context, breadcrumb Breadcrumb)
+	// Flush waits until buffered events are sent or the timeout expires
+	Flush(timeout time.Duration) bool
+}
+
+type Breadcrumb struct {
+	Category string
+	Message  string
+	Level    string
+	Data     map[string]any
+}
+
+// ErrorScope is the request information attached to every reported error.
+type ErrorScope struct {
+	TenantId  string
+	UserId    string
+	RequestId string
+	Route     string
+}
+
+func (s ErrorScope) Tags() map[string]string {
+	tags := map[string]string{}
+	if s.TenantId != "" {
+		tags["tenant_id"] = s.TenantId
+	}
+	if s.UserId != "" {
+		tags["user_id"] = s.UserId
+	}
+	if s.RequestId != "" {
+		tags["request_id"] = s.RequestId
+	}
+	if s.Route != "" {
+		tags["route"] = s.Route
+	}
+	return tags
+}
+
+// ErrorScopeFromContext collects the tenant, user, request id and route available in ctx.
+func ErrorScopeFromContext(ctx context.Context) ErrorScope {
+	scope := ErrorScope{}
+	if ctx == nil {
+		return scope
+	}
+	if c, ok := ctx.(Context); ok {
+		scope.TenantId = c.TenantId()
+		if auth := c.Auth(); auth != nil {
+			scope.UserId = auth.UserId
+		}
+	}
+	if scope.TenantId == "" {
+		scope.TenantId, _ = ctx.Value(TenantKey{}).(string)
+	}
+	if auth, ok := ctx.Value(AuthenticationKey{}).(*Authentication); ok && auth != nil && scope.UserId == "" {
+		scope.UserId = auth.UserId
+	}
+	scope.RequestId, _ = ctx.Value(RequestIdKey{}).(string)
+	scope.Route, _ = ctx.Value(RouteKey{}).(string)
+	return scope
 }
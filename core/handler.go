@@ -0,0 +1,61 @@
+package f
+
+import "net/http"
+
+// Empty can be used as the input or output type of a typed handler that does not
+// expect a payload.
+type Empty struct{}
+
+// TypedHandler receives the bound and validated input of a request and returns the
+// value that is serialized as the JSON response.
+type TypedHandler[In any, Out any] func(c HttpContext, in In) (Out, error)
+
+// Handle registers a typed handler. The input is bound from the headers, query string,
+// path and body, then validated when it is a struct or a pointer to one; binding and
+// validation failures are reported as 400 responses. The output is serialized with
+// route.Status (200 by default); a 204 status produces an empty response.
+func Handle[In any, Out any](router HttpRouter, route Route, handler TypedHandler[In, Out]) {
+	var in In
+	var out Out
+	route.Input = in
+	route.Output = out
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	route.Handler = func(c HttpContext) error {
+		var input In
+		if err := c.ShouldBind(&input); err != nil {
+			return err
+		}
+		output, err := handler(c, input)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusNoContent {
+			return c.NoContent()
+		}
+		return c.JSON(status, output)
+	}
+	router.Handle(route)
+}
+
+func GET[In any, Out any](router HttpRouter, path string, handler TypedHandler[In, Out], middlewares ...Middleware) {
+	Handle(router, Route{Method: http.MethodGet, Path: path, Middlewares: middlewares}, handler)
+}
+
+func POST[In any, Out any](router HttpRouter, path string, handler TypedHandler[In, Out], middlewares ...Middleware) {
+	Handle(router, Route{Method: http.MethodPost, Path: path, Middlewares: middlewares}, handler)
+}
+
+func PUT[In any, Out any](router HttpRouter, path string, handler TypedHandler[In, Out], middlewares ...Middleware) {
+	Handle(router, Route{Method: http.MethodPut, Path: path, Middlewares: middlewares}, handler)
+}
+
+func PATCH[In any, Out any](router HttpRouter, path string, handler TypedHandler[In, Out], middlewares ...Middleware) {
+	Handle(router, Route{Method: http.MethodPatch, Path: path, Middlewares: middlewares}, handler)
+}
+
+func DELETE[In any, Out any](router HttpRouter, path string, handler TypedHandler[In, Out], middlewares ...Middleware) {
+	Handle(router, Route{Method: http.MethodDelete, Path: path, Middlewares: middlewares}, handler)
+}
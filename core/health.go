@@ -1,5 +1,12 @@
 package f
 
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
 type HealthCheckResponse struct {
 	Whoami     string                          `json:"whoami"`
 	Status     string                          `json:"status"`
@@ -29,9 +36,15 @@ func (b *HealthCheck) Add(name string, tester func() error) {
 	var message string
 	status := "UP"
 	if err := tester(); err != nil {
-		status = "DOWN"
 		message = err.Error()
-		b.status = "DOWN"
+		if _, ok := err.(healthyError); ok {
+			status = "UP"
+		} else if _, ok := err.(degradedError); ok {
+			status = "DEGRADED"
+		} else {
+			status = "DOWN"
+			b.status = "DOWN"
+		}
 	}
 	b.components[name] = HealthCheckComponent{
 		Message: message,
@@ -46,3 +59,119 @@ func (b *HealthCheck) Build() HealthCheckResponse {
 		Components: b.components,
 	}
 }
+
+type HealthCheckFunc func(ctx context.Context) error
+
+type degradedError struct {
+	error
+}
+
+// Degraded reports a partial failure: the component is listed as DEGRADED with the
+// message of err but the service stays UP.
+func Degraded(err error) error {
+	if err == nil {
+		return nil
+	}
+	return degradedError{err}
+}
+
+type healthyError struct {
+	message string
+}
+
+func (e healthyError) Error() string {
+	return e.message
+}
+
+// Healthy reports a component that is UP along with an informative message, such as
+// the lag of a replica.
+func Healthy(message string) error {
+	return healthyError{message}
+}
+
+// HealthChecks holds the readiness checks contributed by providers and features.
+// Checks run in parallel, each one bounded by Timeout.
+type HealthChecks struct {
+	Timeout time.Duration
+	mu      sync.RWMutex
+	names   []string
+	checks  map[string]HealthCheckFunc
+}
+
+func NewHealthChecks(timeout time.Duration) *HealthChecks {
+	return &HealthChecks{
+		Timeout: timeout,
+		checks:  make(map[string]HealthCheckFunc),
+	}
+}
+
+// Add registers a check, a check with the same name is replaced
+func (h *HealthChecks) Add(name string, check HealthCheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, exists := h.checks[name]; !exists {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// AddPing registers a check calling a provider Ping method
+func (h *HealthChecks) AddPing(name string, ping func() error) {
+	h.Add(name, func(ctx context.Context) error {
+		return ping()
+	})
+}
+
+// Run executes every check and aggregates the results, the status is DOWN as soon
+// as one check fails or times out.
+func (h *HealthChecks) Run(ctx context.Context, service string) HealthCheckResponse {
+	h.mu.RLock()
+	names := append([]string{}, h.names...)
+	checks := make([]HealthCheckFunc, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	results := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.run(ctx, checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	builder := NewHealthCheck(service)
+	for i, name := range names {
+		err := results[i]
+		builder.Add(name, func() error { return err })
+	}
+	return builder.Build()
+}
+
+func (h *HealthChecks) run(ctx context.Context, check HealthCheckFunc) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	// checks are not required to honor ctx, e.g. Ping() methods
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if value := recover(); value != nil {
+				done <- fmt.Errorf("health check panicked: %v", value)
+			}
+		}()
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("health check timed out: %v", ctx.Err())
+	}
+}
@@ -0,0 +1,59 @@
+package f
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestHealthChecks_Run(t *testing.T) {
+	checks := NewHealthChecks(50 * time.Millisecond)
+	checks.AddPing("cache", func() error { return nil })
+	checks.Add("db", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	res := checks.Run(context.Background(), "demo")
+	assert.Equal(t, res.Whoami, "demo")
+	assert.Equal(t, res.Status, "DOWN")
+	assert.Equal(t, res.Components["cache"].Status, "UP")
+	assert.Equal(t, res.Components["db"].Status, "DOWN")
+	assert.Equal(t, res.Components["db"].Message, "connection refused")
+}
+
+func TestHealthChecks_TimeoutAndParallel(t *testing.T) {
+	checks := NewHealthChecks(50 * time.Millisecond)
+	for _, name := range []string{"a", "b", "c"} {
+		checks.AddPing(name, func() error {
+			time.Sleep(time.Second)
+			return nil
+		})
+	}
+
+	start := time.Now()
+	res := checks.Run(context.Background(), "demo")
+	assert.Equal(t, time.Since(start) < 500*time.Millisecond, true)
+	assert.Equal(t, res.Status, "DOWN")
+	assert.Equal(t, res.Components["a"].Status, "DOWN")
+}
+
+func TestHealthChecks_Degraded(t *testing.T) {
+	checks := NewHealthChecks(50 * time.Millisecond)
+	checks.Add("tenants", func(ctx context.Context) error { return Degraded(errors.New("t1: migration failed")) })
+
+	res := checks.Run(context.Background(), "demo")
+	assert.Equal(t, res.Status, "UP")
+	assert.Equal(t, res.Components["tenants"].Status, "DEGRADED")
+	assert.Equal(t, res.Components["tenants"].Message, "t1: migration failed")
+}
+
+func TestHealthChecks_Healthy(t *testing.T) {
+	checks := NewHealthChecks(50 * time.Millisecond)
+	checks.Add("replicas", func(ctx context.Context) error { return Healthy("default/replica-1: lag 0s") })
+
+	res := checks.Run(context.Background(), "demo")
+	assert.Equal(t, res.Status, "UP")
+	assert.Equal(t, res.Components["replicas"].Status, "UP")
+	assert.Equal(t, res.Components["replicas"].Message, "default/replica-1: lag 0s")
+}
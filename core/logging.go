@@ -0,0 +1,36 @@
+package f
+
+import (
+	"context"
+
+	"github.com/soffa-projects/foundation-go/log"
+)
+
+type FeatureKey struct{}
+
+func init() {
+	log.RegisterContextExtractor(logFields)
+}
+
+// WithFeature records the feature handling ctx, it is reported by log.FromContext
+func WithFeature(ctx context.Context, feature string) context.Context {
+	return context.WithValue(ctx, FeatureKey{}, feature)
+}
+
+func logFields(ctx context.Context) []any {
+	scope := ErrorScopeFromContext(ctx)
+	var fields []any
+	if scope.RequestId != "" {
+		fields = append(fields, "request_id", scope.RequestId)
+	}
+	if scope.TenantId != "" {
+		fields = append(fields, "tenant_id", scope.TenantId)
+	}
+	if scope.UserId != "" {
+		fields = append(fields, "user_id", scope.UserId)
+	}
+	if feature, _ := ctx.Value(FeatureKey{}).(string); feature != "" {
+		fields = append(fields, "feature", feature)
+	}
+	return fields
+}
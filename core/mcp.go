@@ -7,6 +7,8 @@ import (
 type MCPServerConfig struct {
 	ToolsCapabilities   bool
 	PromptsCapabilities bool
+	// ErrorReporter receives tool panics and server errors, when set
+	ErrorReporter ErrorReporter
 }
 
 type MCPServer interface {
@@ -0,0 +1,49 @@
+package f
+
+import "net/http"
+
+// Metrics creates application metrics. Calling a constructor twice with the same
+// name returns the metric registered first.
+type Metrics interface {
+	Counter(name string, help string, labels ...string) Counter
+	Gauge(name string, help string, labels ...string) Gauge
+	// Histogram uses the default buckets when buckets is empty
+	Histogram(name string, help string, buckets []float64, labels ...string) Histogram
+	// Handler serves the metrics in the Prometheus text format
+	Handler() http.Handler
+}
+
+// Counter label values are given in the order the labels were declared
+type Counter interface {
+	Inc(labelValues ...string)
+	Add(value float64, labelValues ...string)
+}
+
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+	Inc(labelValues ...string)
+	Dec(labelValues ...string)
+	Add(value float64, labelValues ...string)
+}
+
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// NoopMetrics discards every measure, it is used when metrics are disabled
+type NoopMetrics struct{}
+
+func (NoopMetrics) Counter(name string, help string, labels ...string) Counter { return noopMetric{} }
+func (NoopMetrics) Gauge(name string, help string, labels ...string) Gauge     { return noopMetric{} }
+func (NoopMetrics) Histogram(name string, help string, buckets []float64, labels ...string) Histogram {
+	return noopMetric{}
+}
+func (NoopMetrics) Handler() http.Handler { return http.NotFoundHandler() }
+
+type noopMetric struct{}
+
+func (noopMetric) Inc(labelValues ...string)                    {}
+func (noopMetric) Dec(labelValues ...string)                    {}
+func (noopMetric) Add(value float64, labelValues ...string)     {}
+func (noopMetric) Set(value float64, labelValues ...string)     {}
+func (noopMetric) Observe(value float64, labelValues ...string) {}
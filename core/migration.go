@@ -0,0 +1,148 @@
+package f
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MigrationAction string
+
+const (
+	MigrateStatus MigrationAction = "status"
+	MigrateUp     MigrationAction = "up"
+	MigrateUpTo   MigrationAction = "up-to"
+	MigrateDown   MigrationAction = "down"
+	MigrateDownTo MigrationAction = "down-to"
+	MigrateRedo   MigrationAction = "redo"
+)
+
+// MigrationCommand runs against the default database and every tenant database,
+// or only the ones listed in Tenants (ids or slugs, "default" for the default database and
+// "tenants" for the tenant tables of the discriminator strategy).
+type MigrationCommand struct {
+	Action MigrationAction
+	// Version targeted by up-to and down-to
+	Version int64
+	// Steps rolled back by down, 1 by default
+	Steps int
+	// DryRun reports the SQL of the migrations that would run without executing it
+	DryRun bool
+	// ContinueOnError migrates the next databases when one fails
+	ContinueOnError bool
+	Tenants         []string
+}
+
+// GoMigration is a migration written in Go, such as a data backfill. It is ordered with
+// the SQL migrations by version and tracked in the same changelog table. Up and Down run
+// within the transaction recording the version, cnx being bound to ctx as the default or
+// tenant connection. A nil Down only forgets the version on rollback.
+type GoMigration struct {
+	Version int64
+	// Name identifies the migration in reports, as the file name of SQL migrations does
+	Name string
+	// Tenant runs the migration on the tenant databases instead of the default one
+	Tenant bool
+	Up     func(ctx context.Context, cnx Connection) error
+	Down   func(ctx context.Context, cnx Connection) error
+}
+
+type MigrationStatus struct {
+	Version   int64     `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"appliedAt,omitzero"`
+}
+
+type MigrationStep struct {
+	Version   int64         `json:"version"`
+	Name      string        `json:"name"`
+	Direction string        `json:"direction"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	// SQL is only set by dry runs
+	SQL string `json:"sql,omitempty"`
+}
+
+type MigrationReport struct {
+	Tenant string `json:"tenant"`
+	// Version of the database once the command ran
+	Version int64 `json:"version"`
+	// Migrations is the status of every migration, set by the status action
+	Migrations []MigrationStatus `json:"migrations,omitempty"`
+	// Steps are the migrations applied or rolled back, or planned by a dry run
+	Steps []MigrationStep `json:"steps,omitempty"`
+	Err   error           `json:"-"`
+}
+
+type Migrator interface {
+	// Migrate returns one report per database, the error joins the failures
+	Migrate(ctx context.Context, cmd MigrationCommand) ([]MigrationReport, error)
+}
+
+// ParseMigrationCommand parses command line arguments:
+//
+//	status | up | up-to VERSION | down [STEPS] | down-to VERSION | redo
+//	[--dry-run] [--continue-on-error] [--tenant ID]...
+func ParseMigrationCommand(args []string) (MigrationCommand, error) {
+	cmd := MigrationCommand{}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--dry-run":
+			cmd.DryRun = true
+		case "--continue-on-error":
+			cmd.ContinueOnError = true
+		case "--tenant":
+			if i+1 >= len(args) {
+				return cmd, fmt.Errorf("--tenant requires a value")
+			}
+			i++
+			cmd.Tenants = append(cmd.Tenants, strings.Split(args[i], ",")...)
+		default:
+			if value, ok := strings.CutPrefix(arg, "--tenant="); ok {
+				cmd.Tenants = append(cmd.Tenants, strings.Split(value, ",")...)
+			} else if strings.HasPrefix(arg, "-") {
+				return cmd, fmt.Errorf("unknown flag: %s", arg)
+			} else {
+				positional = append(positional, arg)
+			}
+		}
+	}
+	if len(positional) == 0 {
+		return cmd, fmt.Errorf("missing migration action")
+	}
+	cmd.Action = MigrationAction(positional[0])
+	args = positional[1:]
+	switch cmd.Action {
+	case MigrateStatus, MigrateUp, MigrateRedo:
+		if len(args) > 0 {
+			return cmd, fmt.Errorf("%s takes no argument", cmd.Action)
+		}
+	case MigrateUpTo, MigrateDownTo:
+		if len(args) != 1 {
+			return cmd, fmt.Errorf("%s requires a version", cmd.Action)
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return cmd, fmt.Errorf("invalid version: %s", args[0])
+		}
+		cmd.Version = version
+	case MigrateDown:
+		cmd.Steps = 1
+		if len(args) > 1 {
+			return cmd, fmt.Errorf("down takes at most one argument")
+		}
+		if len(args) == 1 {
+			steps, err := strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				return cmd, fmt.Errorf("invalid number of steps: %s", args[0])
+			}
+			cmd.Steps = steps
+		}
+	default:
+		return cmd, fmt.Errorf("unknown migration action: %s", cmd.Action)
+	}
+	return cmd, nil
+}
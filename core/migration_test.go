@@ -0,0 +1,34 @@
+package f
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestParseMigrationCommand(t *testing.T) {
+	cmd, err := ParseMigrationCommand([]string{"down", "3", "--dry-run", "--tenant", "t1,t2", "--continue-on-error"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cmd, MigrationCommand{
+		Action:          MigrateDown,
+		Steps:           3,
+		DryRun:          true,
+		ContinueOnError: true,
+		Tenants:         []string{"t1", "t2"},
+	})
+
+	cmd, err = ParseMigrationCommand([]string{"--tenant=default", "up-to", "20250101"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cmd.Action, MigrateUpTo)
+	assert.Equal(t, cmd.Version, int64(20250101))
+	assert.Equal(t, cmd.Tenants, []string{"default"})
+
+	cmd, err = ParseMigrationCommand([]string{"down"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cmd.Steps, 1)
+
+	for _, args := range [][]string{{}, {"sideways"}, {"up-to"}, {"down", "0"}, {"status", "1"}, {"up", "--force"}} {
+		_, err = ParseMigrationCommand(args)
+		assert.NotEqual(t, err, nil)
+	}
+}
@@ -0,0 +1,14 @@
+package f
+
+import "context"
+
+// Outbox defers messages until the transaction of the request commits: they are
+// written to the outbox table of the connection bound to the context (the tenant one
+// when present, the default one otherwise) and a relay delivers them afterwards, at
+// least once. A rolled back transaction discards its messages.
+type Outbox interface {
+	Publish(ctx context.Context, topic string, message string) error
+	Enqueue(ctx context.Context, jobType JobType, data any) error
+	// FireEvent fires evt once delivered, data is carried as JSON
+	FireEvent(ctx context.Context, evt string, data map[string]any) error
+}
@@ -0,0 +1,123 @@
+package f
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/soffa-projects/foundation-go/errors"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type PageMode int
+
+const (
+	// OffsetPaging addresses pages by number and counts the total
+	OffsetPaging PageMode = iota
+	// CursorPaging walks the rows from an opaque cursor (keyset pagination), the rows
+	// are ordered by the requested sorts followed by the primary key.
+	CursorPaging
+)
+
+type PageRequest struct {
+	Mode PageMode
+	// Page is 1-based, used by OffsetPaging
+	Page int
+	Size int
+	// Cursor is the NextCursor or PrevCursor of a previous page, empty for the first page
+	Cursor string
+}
+
+// PageSize returns Size bounded to [1, MaxPageSize], DefaultPageSize when unset
+func (r PageRequest) PageSize() int {
+	if r.Size <= 0 {
+		return DefaultPageSize
+	}
+	return min(r.Size, MaxPageSize)
+}
+
+// PageNumber returns Page, at least 1
+func (r PageRequest) PageNumber() int {
+	return max(r.Page, 1)
+}
+
+type PageInfo struct {
+	Page int `json:"page,omitempty"`
+	Size int `json:"size"`
+	// Total is only counted by OffsetPaging
+	Total      *int   `json:"total,omitempty"`
+	HasNext    bool   `json:"hasNext"`
+	HasPrev    bool   `json:"hasPrev"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// Page is the standard envelope of list endpoints
+type Page[T any] struct {
+	Items []T `json:"items"`
+	PageInfo
+}
+
+// ParsePageRequest reads the page, size and cursor query parameters of c
+func ParsePageRequest(c HttpContext, mode PageMode) (PageRequest, error) {
+	req := PageRequest{Mode: mode, Cursor: c.QueryParam("cursor")}
+	for name, target := range map[string]*int{"page": &req.Page, "size": &req.Size} {
+		value := c.QueryParam(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return req, errors.BadRequest(fmt.Sprintf("invalid %s parameter: %s", name, value))
+		}
+		*target = n
+	}
+	return req, nil
+}
+
+// SetPageLinks emits the RFC 8288 Link header pointing to the pages around info
+func SetPageLinks(c HttpContext, info PageInfo) {
+	uri, err := url.ParseRequestURI(c.RequestURI())
+	if err != nil {
+		return
+	}
+	link := func(rel string, params map[string]string) string {
+		query := uri.Query()
+		query.Del("page")
+		query.Del("cursor")
+		query.Set("size", strconv.Itoa(info.Size))
+		for key, value := range params {
+			query.Set(key, value)
+		}
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, uri.Path, query.Encode(), rel)
+	}
+	var links []string
+	if info.NextCursor != "" || info.PrevCursor != "" {
+		if info.PrevCursor != "" {
+			links = append(links, link("prev", map[string]string{"cursor": info.PrevCursor}))
+		}
+		if info.NextCursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": info.NextCursor}))
+		}
+	} else if info.Page > 0 {
+		page := func(n int) map[string]string { return map[string]string{"page": strconv.Itoa(n)} }
+		links = append(links, link("first", page(1)))
+		if info.HasPrev {
+			links = append(links, link("prev", page(info.Page-1)))
+		}
+		if info.HasNext {
+			links = append(links, link("next", page(info.Page+1)))
+		}
+		if info.Total != nil {
+			links = append(links, link("last", page(max(1, (*info.Total+info.Size-1)/info.Size))))
+		}
+	}
+	if len(links) > 0 {
+		c.SetHeader("Link", strings.Join(links, ", "))
+	}
+}
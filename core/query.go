@@ -0,0 +1,260 @@
+package f
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/soffa-projects/foundation-go/errors"
+)
+
+// ------------------------------------------------------------------------------------------------------------------
+// FILTERS
+// ------------------------------------------------------------------------------------------------------------------
+
+type Operator string
+
+const (
+	OpEq      Operator = "eq"
+	OpNe      Operator = "ne"
+	OpGt      Operator = "gt"
+	OpGte     Operator = "gte"
+	OpLt      Operator = "lt"
+	OpLte     Operator = "lte"
+	OpIn      Operator = "in"
+	OpLike    Operator = "like"
+	OpILike   Operator = "ilike"
+	OpBetween Operator = "between"
+	OpNull    Operator = "null"
+	OpNotNull Operator = "notnull"
+)
+
+// Condition is a typed WHERE clause, built with Eq, In, Like, Between, IsNull, And, Or...
+// Column names are quoted when compiled and values are always bound as parameters.
+type Condition interface {
+	condition()
+}
+
+// Filter compares a column with its values, Between takes a lower and upper bound
+// (nil for unbounded) and In any number of values.
+type Filter struct {
+	Column string
+	Op     Operator
+	Values []any
+}
+
+// Group combines conditions with AND, or with OR when Or is set
+type Group struct {
+	Or         bool
+	Conditions []Condition
+}
+
+func (Filter) condition() {}
+func (Group) condition()  {}
+
+func Eq(column string, value any) Condition  { return Filter{column, OpEq, []any{value}} }
+func Ne(column string, value any) Condition  { return Filter{column, OpNe, []any{value}} }
+func Gt(column string, value any) Condition  { return Filter{column, OpGt, []any{value}} }
+func Gte(column string, value any) Condition { return Filter{column, OpGte, []any{value}} }
+func Lt(column string, value any) Condition  { return Filter{column, OpLt, []any{value}} }
+func Lte(column string, value any) Condition { return Filter{column, OpLte, []any{value}} }
+func In(column string, values ...any) Condition {
+	return Filter{column, OpIn, values}
+}
+
+// Like matches a pattern using % and _ wildcards, ILike ignores the case
+func Like(column string, pattern string) Condition  { return Filter{column, OpLike, []any{pattern}} }
+func ILike(column string, pattern string) Condition { return Filter{column, OpILike, []any{pattern}} }
+
+// Between matches from <= column <= to, a nil bound is ignored
+func Between(column string, from any, to any) Condition {
+	return Filter{column, OpBetween, []any{from, to}}
+}
+
+func IsNull(column string) Condition  { return Filter{Column: column, Op: OpNull} }
+func NotNull(column string) Condition { return Filter{Column: column, Op: OpNotNull} }
+
+func And(conditions ...Condition) Condition { return Group{Conditions: conditions} }
+func Or(conditions ...Condition) Condition  { return Group{Or: true, Conditions: conditions} }
+
+// ------------------------------------------------------------------------------------------------------------------
+// SORTS
+// ------------------------------------------------------------------------------------------------------------------
+
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+func Asc(column string) Sort  { return Sort{Column: column} }
+func Desc(column string) Sort { return Sort{Column: column, Desc: true} }
+
+// ------------------------------------------------------------------------------------------------------------------
+// QUERY SCHEMA
+// ------------------------------------------------------------------------------------------------------------------
+
+// QuerySchema whitelists the columns of an entity that clients may filter and sort on,
+// it turns the ?filter= and ?sort= parameters of list endpoints into QueryOpts.
+//
+// Filters are comma separated (AND) column:op:value terms, grouped with or(...) and
+// and(...), in and between values are separated by |:
+//
+//	?filter=status:in:active|trial,or(name:ilike:%doe%,age:between:18|65),deleted_at:null
+//	?sort=-created_at,name
+type QuerySchema struct {
+	Filterable []string
+	Sortable   []string
+}
+
+// Parse validates filter and sort against the schema, failures are bad requests
+func (s QuerySchema) Parse(filter string, sort string) (QueryOpts, error) {
+	var opts QueryOpts
+	if strings.TrimSpace(filter) != "" {
+		cond, err := s.ParseFilter(filter)
+		if err != nil {
+			return opts, err
+		}
+		opts.Filter = cond
+	}
+	if strings.TrimSpace(sort) != "" {
+		sorts, err := s.ParseSort(sort)
+		if err != nil {
+			return opts, err
+		}
+		opts.Sort = sorts
+	}
+	return opts, nil
+}
+
+// FromRequest parses the filter and sort query parameters of c
+func (s QuerySchema) FromRequest(c HttpContext) (QueryOpts, error) {
+	return s.Parse(c.QueryParam("filter"), c.QueryParam("sort"))
+}
+
+func (s QuerySchema) ParseFilter(filter string) (Condition, error) {
+	terms, err := splitTerms(filter)
+	if err != nil {
+		return nil, err
+	}
+	conditions := make([]Condition, 0, len(terms))
+	for _, term := range terms {
+		cond, err := s.parseTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return And(conditions...), nil
+}
+
+func (s QuerySchema) ParseSort(sort string) ([]Sort, error) {
+	var sorts []Sort
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		desc := strings.HasPrefix(field, "-")
+		column := strings.TrimPrefix(strings.TrimPrefix(field, "-"), "+")
+		if !slices.Contains(s.Sortable, column) {
+			return nil, errors.BadRequest(fmt.Sprintf("sorting on %q is not allowed", column))
+		}
+		sorts = append(sorts, Sort{Column: column, Desc: desc})
+	}
+	return sorts, nil
+}
+
+func (s QuerySchema) parseTerm(term string) (Condition, error) {
+	for _, group := range []string{"or", "and"} {
+		if strings.HasPrefix(term, group+"(") && strings.HasSuffix(term, ")") {
+			inner, err := s.ParseFilter(term[len(group)+1 : len(term)-1])
+			if err != nil {
+				return nil, err
+			}
+			conditions := []Condition{inner}
+			if g, ok := inner.(Group); ok && !g.Or {
+				conditions = g.Conditions
+			}
+			return Group{Or: group == "or", Conditions: conditions}, nil
+		}
+	}
+
+	parts := strings.SplitN(term, ":", 3)
+	if len(parts) < 2 {
+		return nil, errors.BadRequest(fmt.Sprintf("invalid filter %q, expected column:op:value", term))
+	}
+	column, op := parts[0], Operator(strings.ToLower(parts[1]))
+	if !slices.Contains(s.Filterable, column) {
+		return nil, errors.BadRequest(fmt.Sprintf("filtering on %q is not allowed", column))
+	}
+	if op == OpNull || op == OpNotNull {
+		return Filter{Column: column, Op: op}, nil
+	}
+	if len(parts) < 3 {
+		return nil, errors.BadRequest(fmt.Sprintf("invalid filter %q, missing value", term))
+	}
+	value := parts[2]
+	switch op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike, OpILike:
+		return Filter{column, op, []any{value}}, nil
+	case OpIn:
+		var values []any
+		for _, v := range strings.Split(value, "|") {
+			values = append(values, v)
+		}
+		return Filter{column, op, values}, nil
+	case OpBetween:
+		bounds := strings.Split(value, "|")
+		if len(bounds) != 2 {
+			return nil, errors.BadRequest(fmt.Sprintf("invalid filter %q, expected between:from|to", term))
+		}
+		var from, to any
+		if bounds[0] != "" {
+			from = bounds[0]
+		}
+		if bounds[1] != "" {
+			to = bounds[1]
+		}
+		return Filter{column, op, []any{from, to}}, nil
+	}
+	return nil, errors.BadRequest(fmt.Sprintf("unsupported filter operator %q", op))
+}
+
+// splitTerms splits filter on the commas which are not nested in a group
+func splitTerms(filter string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range filter {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, errors.BadRequest("invalid filter, unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, filter[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.BadRequest("invalid filter, unbalanced parentheses")
+	}
+	terms = append(terms, filter[start:])
+	result := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			result = append(result, term)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.BadRequest("invalid filter, empty group")
+	}
+	return result, nil
+}
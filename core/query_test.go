@@ -0,0 +1,45 @@
+package f
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/soffa-projects/foundation-go/errors"
+)
+
+var usersSchema = QuerySchema{
+	Filterable: []string{"name", "age", "status", "deleted_at"},
+	Sortable:   []string{"name", "created_at"},
+}
+
+func TestQuerySchema_Parse(t *testing.T) {
+	opts, err := usersSchema.Parse("status:in:active|trial,or(name:ilike:%doe%,age:between:18|),deleted_at:null", "-created_at,name")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts.Filter, And(
+		In("status", "active", "trial"),
+		Or(ILike("name", "%doe%"), Between("age", "18", nil)),
+		IsNull("deleted_at"),
+	))
+	assert.Equal(t, opts.Sort, []Sort{Desc("created_at"), Asc("name")})
+
+	opts, err = usersSchema.Parse("name:eq:a:b", "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts.Filter, Eq("name", "a:b"))
+	assert.Equal(t, opts.Sort, nil)
+}
+
+func TestQuerySchema_RejectsInvalidInput(t *testing.T) {
+	for _, input := range []struct{ filter, sort string }{
+		{"password:eq:secret", ""},
+		{"name:regex:.*", ""},
+		{"name", ""},
+		{"name:eq", ""},
+		{"or(name:eq:a", ""},
+		{"age:between:18", ""},
+		{"", "-password"},
+	} {
+		_, err := usersSchema.Parse(input.filter, input.sort)
+		assert.Equal(t, errors.GetStatusCode(err), http.StatusBadRequest)
+	}
+}
@@ -2,6 +2,7 @@ package f
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -22,3 +23,24 @@ type QueueClient interface {
 	Enqueue(ctx context.Context, jobType string, data any) (string, error)
 	Close() error
 }
+
+// Job is a task delivered to a QueueWorker handler
+type Job struct {
+	ID      string
+	Type    JobType
+	Payload []byte
+}
+
+// Bind decodes the JSON payload of the job into value
+func (j Job) Bind(value any) error {
+	return json.Unmarshal(j.Payload, value)
+}
+
+type JobHandler func(ctx context.Context, job Job) error
+
+type QueueWorker interface {
+	Handle(jobType JobType, handler JobHandler)
+	Start() error
+	// Shutdown stops fetching jobs and waits for the running ones until ctx is done
+	Shutdown(ctx context.Context) error
+}
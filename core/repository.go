@@ -0,0 +1,177 @@
+package f
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/soffa-projects/foundation-go/errors"
+)
+
+// ErrNotFound is the cause of the errors of Repository lookups matching no row, they
+// render as a 404. Test it with errors.Is.
+var ErrNotFound = stderrors.New("entity not found")
+
+func errMissingConnection() error {
+	return errors.Technical("no database connection in context, check the tenant middleware")
+}
+
+// Repository gives typed access to the entities of type T stored in the connection
+// bound to the context: the tenant one when present, the default one otherwise.
+type Repository[T any] struct {
+	// DefaultTenant always uses the default connection, for entities shared by all tenants
+	DefaultTenant bool
+}
+
+func NewRepository[T any]() *Repository[T] {
+	return &Repository[T]{}
+}
+
+// NewDefaultRepository creates a repository bound to the default connection
+func NewDefaultRepository[T any]() *Repository[T] {
+	return &Repository[T]{DefaultTenant: true}
+}
+
+// Connection returns the connection used by the repository for ctx, like EntityManager.Current
+func (r *Repository[T]) Connection(ctx context.Context) (Connection, error) {
+	if !r.DefaultTenant {
+		if cnx, ok := ctx.Value(TenantCnxKey{}).(Connection); ok && cnx != nil {
+			return cnx, nil
+		}
+	}
+	if cnx, ok := ctx.Value(DefaultCnxKey{}).(Connection); ok && cnx != nil {
+		return cnx, nil
+	}
+	return nil, errMissingConnection()
+}
+
+// FindById returns the entity whose primary key is id, or ErrNotFound. Entities with a
+// composite primary key are looked up with FindOne.
+func (r *Repository[T]) FindById(ctx context.Context, id any) (*T, error) {
+	if keys := primaryKeys(reflect.TypeFor[T]()); keys != 1 {
+		return nil, errors.Technical(fmt.Sprintf("FindById needs a single primary key column, %s has %d", reflect.TypeFor[T](), keys))
+	}
+	// ?TablePKs stands for the primary key column of T, whatever its name
+	return r.FindOne(ctx, "?TablePKs = ?", id)
+}
+
+// FindOne returns the first entity matching where, or ErrNotFound
+func (r *Repository[T]) FindOne(ctx context.Context, where string, args ...any) (*T, error) {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entity := new(T)
+	notFound, err := cnx.Query(ctx, entity, QueryOpts{Where: where, Args: args, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if notFound {
+		return nil, errors.New(http.StatusNotFound, "", ErrNotFound.Error()).WithCause(ErrNotFound)
+	}
+	return entity, nil
+}
+
+// FindAll returns the entities matching opts, an empty slice when there are none
+func (r *Repository[T]) FindAll(ctx context.Context, opts ...QueryOpts) ([]T, error) {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entities := []T{}
+	if _, err := cnx.Query(ctx, &entities, opts...); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Paginate returns the page of entities matching opts described by req
+func (r *Repository[T]) Paginate(ctx context.Context, req PageRequest, opts ...QueryOpts) (*Page[T], error) {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page[T]{Items: []T{}}
+	if page.PageInfo, err = cnx.Paginate(ctx, &page.Items, req, opts...); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *Repository[T]) Exists(ctx context.Context, where string, args ...any) (bool, error) {
+	count, err := r.Count(ctx, where, args...)
+	return count > 0, err
+}
+
+// Count returns the number of entities matching where, all of them when where is empty
+func (r *Repository[T]) Count(ctx context.Context, where string, args ...any) (int, error) {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if where == "" {
+		return cnx.Count(ctx, (*T)(nil))
+	}
+	return cnx.CountBy(ctx, (*T)(nil), where, args...)
+}
+
+func (r *Repository[T]) Insert(ctx context.Context, entity *T) error {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return err
+	}
+	return cnx.Insert(ctx, entity)
+}
+
+// Update saves entity by primary key, only the given columns when any
+func (r *Repository[T]) Update(ctx context.Context, entity *T, columns ...string) error {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return err
+	}
+	return cnx.Update(ctx, entity, columns...)
+}
+
+func (r *Repository[T]) Delete(ctx context.Context, entity *T) error {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return err
+	}
+	return cnx.Delete(ctx, entity)
+}
+
+// Upsert inserts entity or updates the row conflicting on conflictColumns,
+// the primary key by default.
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, conflictColumns ...string) error {
+	cnx, err := r.Connection(ctx)
+	if err != nil {
+		return err
+	}
+	return cnx.Upsert(ctx, entity, conflictColumns...)
+}
+
+// primaryKeys counts the columns tagged pk in t, the embedded structs included
+func primaryKeys(t reflect.Type) int {
+	if t.Kind() != reflect.Struct {
+		return 0
+	}
+	count := 0
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("bun")
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			count += primaryKeys(field.Type)
+			continue
+		}
+		options := strings.Split(tag, ",")
+		for _, option := range options[1:] {
+			if option == "pk" {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -12,6 +12,12 @@ type RouterConfig struct {
 	//FaviconFS     fs.FS
 	SessionSecret string
 	SentryDSN     string
+	// LegacyErrorFormat keeps the {requestId,timestamp,uri,error,success} error body
+	// instead of application/problem+json
+	LegacyErrorFormat bool
+	// TxRetries is the number of times a handler is retried on a serialization failure,
+	// see TxOptions.Retries
+	TxRetries int
 	//Env           string
 	//Debug         bool
 }
@@ -24,6 +30,71 @@ type Router interface {
 	Shutdown(ctx context.Context) error
 	MCP(path string, handler http.Handler)
 	Use(middleware Middleware)
+	Routes() []Route
+	// SetReady toggles the readiness probe, requests keep being served while not ready
+	SetReady(ready bool)
+}
+
+// Route describes an endpoint registered through HttpRouter. Input and Output
+// are optional sample values (usually zero structs) used to document the
+// request and response payloads in the generated OpenAPI document.
+type Route struct {
+	Method      string
+	Path        string
+	Handler     func(c HttpContext) error
+	Middlewares []Middleware
+	Summary     string
+	Description string
+	Tags        []string
+	Input       any
+	Output      any
+	Status      int
+	// Feature is the name of the feature that registered the route, it is added
+	// to the request context under FeatureKey
+	Feature string
+	// Transaction configures the transactions of the default and tenant connections
+	Transaction TxOptions
+}
+
+// ForFeature returns a router recording feature as the owner of every route it registers.
+func ForFeature(router HttpRouter, feature string) HttpRouter {
+	return &featureRouter{internal: router, feature: feature}
+}
+
+type featureRouter struct {
+	internal HttpRouter
+	feature  string
+}
+
+func (r *featureRouter) Group(path string, middlewares ...Middleware) HttpRouter {
+	return &featureRouter{internal: r.internal.Group(path, middlewares...), feature: r.feature}
+}
+
+func (r *featureRouter) Handle(route Route) {
+	if route.Feature == "" {
+		route.Feature = r.feature
+	}
+	r.internal.Handle(route)
+}
+
+func (r *featureRouter) GET(path string, handler func(c HttpContext) error, middlewares ...Middleware) {
+	r.Handle(Route{Method: http.MethodGet, Path: path, Handler: handler, Middlewares: middlewares})
+}
+
+func (r *featureRouter) POST(path string, handler func(c HttpContext) error, middlewares ...Middleware) {
+	r.Handle(Route{Method: http.MethodPost, Path: path, Handler: handler, Middlewares: middlewares})
+}
+
+func (r *featureRouter) DELETE(path string, handler func(c HttpContext) error, middlewares ...Middleware) {
+	r.Handle(Route{Method: http.MethodDelete, Path: path, Handler: handler, Middlewares: middlewares})
+}
+
+func (r *featureRouter) PUT(path string, handler func(c HttpContext) error, middlewares ...Middleware) {
+	r.Handle(Route{Method: http.MethodPut, Path: path, Handler: handler, Middlewares: middlewares})
+}
+
+func (r *featureRouter) PATCH(path string, handler func(c HttpContext) error, middlewares ...Middleware) {
+	r.Handle(Route{Method: http.MethodPatch, Path: path, Handler: handler, Middlewares: middlewares})
 }
 
 /*
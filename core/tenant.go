@@ -1,6 +1,7 @@
 package f
 
 import (
+	"context"
 	"io/fs"
 
 	"github.com/soffa-projects/foundation-go/errors"
@@ -8,7 +9,15 @@ import (
 	"github.com/soffa-projects/foundation-go/log"
 )
 
-const TenantCreatedEvent = "tenant_created"
+const (
+	TenantCreatedEvent = "tenant_created"
+	// TenantAddedEvent, TenantUpdatedEvent and TenantRemovedEvent are fired by the data
+	// source once the connection of a tenant is opened, replaced or closed, the tenant
+	// is carried as data["data"].
+	TenantAddedEvent   = "tenant_added"
+	TenantUpdatedEvent = "tenant_updated"
+	TenantRemovedEvent = "tenant_removed"
+)
 
 type TenantInput struct {
 	Tenant string `param:"tenant" header:"X-TenantId" json:"-" validate:"required"`
@@ -19,6 +28,15 @@ type TenantProviderConfig struct {
 	MigrationsFS       fs.FS
 }
 
+// CurrentTenant returns the id of the tenant ctx is bound to, if any
+func CurrentTenant(ctx context.Context) string {
+	if c, ok := ctx.(Context); ok && c.TenantId() != "" {
+		return c.TenantId()
+	}
+	tenantId, _ := ctx.Value(TenantKey{}).(string)
+	return tenantId
+}
+
 func TenantMiddleware(c Context) error {
 	if c.TenantId() == "" {
 		return errors.BadRequest("TENANT_REQUIRED_000")
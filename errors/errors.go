@@ -3,11 +3,47 @@ package errors
 import (
 	"errors"
 	"net/http"
+	"strings"
+)
+
+const (
+	CodeTechnical        = "TECHNICAL_ERROR"
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeUnavailable      = "SERVICE_UNAVAILABLE"
 )
 
 type CustomError struct {
 	Code    int
 	Message string
+	// ErrorCode is a stable, machine-readable identifier of the error
+	ErrorCode string
+	Details   []ErrorDetail
+	Cause     error
+}
+
+// ErrorDetail describes a single invalid field of a request.
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag,omitempty"`
+	Message string `json:"message"`
+}
+
+// ProblemDetails is the RFC 7807 (application/problem+json) representation of an error.
+type ProblemDetails struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Status    int           `json:"status"`
+	Detail    string        `json:"detail,omitempty"`
+	Instance  string        `json:"instance,omitempty"`
+	Code      string        `json:"code"`
+	RequestId string        `json:"requestId,omitempty"`
+	Timestamp string        `json:"timestamp,omitempty"`
+	Errors    []ErrorDetail `json:"errors,omitempty"`
 }
 
 // FIXED: Use pointer receiver to enable proper error comparison with errors.Is()
@@ -15,46 +51,72 @@ func (e *CustomError) Error() string {
 	return e.Message
 }
 
-func Technical(message string) error {
+func (e *CustomError) Unwrap() error {
+	return e.Cause
+}
+
+// WithCode overrides the machine-readable code of the error
+func (e *CustomError) WithCode(code string) *CustomError {
+	e.ErrorCode = code
+	return e
+}
+
+// WithDetails attaches field-level details to the error
+func (e *CustomError) WithDetails(details ...ErrorDetail) *CustomError {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// WithCause records the underlying error, which stays reachable through errors.Is/As
+func (e *CustomError) WithCause(cause error) *CustomError {
+	e.Cause = cause
+	return e
+}
+
+// New creates an error with the given HTTP status and machine-readable code.
+// When code is empty, a default code is derived from the status.
+func New(status int, code string, message string) *CustomError {
+	if code == "" {
+		code = DefaultErrorCode(status)
+	}
 	return &CustomError{
-		Code:    http.StatusInternalServerError,
-		Message: message,
+		Code:      status,
+		Message:   message,
+		ErrorCode: code,
 	}
 }
 
+func Technical(message string) error {
+	return New(http.StatusInternalServerError, CodeTechnical, message)
+}
+
 func BadRequest(message string) error {
-	return &CustomError{
-		Code:    http.StatusBadRequest,
-		Message: message,
-	}
+	return New(http.StatusBadRequest, CodeBadRequest, message)
 }
 
 func Unauthorized(message string) error {
-	return &CustomError{
-		Code:    http.StatusUnauthorized,
-		Message: message,
-	}
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
 }
 
 func Forbidden(message string) error {
-	return &CustomError{
-		Code:    http.StatusForbidden,
-		Message: message,
-	}
+	return New(http.StatusForbidden, CodeForbidden, message)
 }
 
 func NotFound(message string) error {
-	return &CustomError{
-		Code:    http.StatusNotFound,
-		Message: message,
-	}
+	return New(http.StatusNotFound, CodeNotFound, message)
 }
 
 func Conflict(message string) error {
-	return &CustomError{
-		Code:    http.StatusConflict,
-		Message: message,
-	}
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func ServiceUnavailable(message string) error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// Validation creates a 400 error listing the invalid fields
+func Validation(details ...ErrorDetail) error {
+	return New(http.StatusBadRequest, CodeValidationFailed, "validation failed").WithDetails(details...)
 }
 
 // GetStatusCode extracts HTTP status code from error
@@ -66,6 +128,33 @@ func GetStatusCode(err error) int {
 	return http.StatusInternalServerError
 }
 
+// GetErrorCode extracts the machine-readable code from error
+func GetErrorCode(err error) string {
+	var ce *CustomError
+	if errors.As(err, &ce) {
+		if ce.ErrorCode != "" {
+			return ce.ErrorCode
+		}
+		return DefaultErrorCode(ce.Code)
+	}
+	return CodeTechnical
+}
+
+// DefaultErrorCode derives a machine-readable code from an HTTP status, e.g. 404 -> NOT_FOUND
+func DefaultErrorCode(status int) string {
+	switch status {
+	case http.StatusInternalServerError:
+		return CodeTechnical
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return CodeTechnical
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
 // Deprecated: Use errors.Is from standard library instead
 func Is(err error, target error) bool {
 	return errors.Is(err, target)
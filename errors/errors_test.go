@@ -101,6 +101,7 @@ func TestGetStatusCode_WithCustomError(t *testing.T) {
 		{"Forbidden", Forbidden("error"), http.StatusForbidden},
 		{"NotFound", NotFound("error"), http.StatusNotFound},
 		{"Conflict", Conflict("error"), http.StatusConflict},
+		{"ServiceUnavailable", ServiceUnavailable("error"), http.StatusServiceUnavailable},
 	}
 
 	for _, tt := range tests {
@@ -216,3 +217,42 @@ func TestCustomError_SpecialCharacters(t *testing.T) {
 	err := BadRequest(msg)
 	assert.Equal(t, err.Error(), msg)
 }
+
+func TestDefaultErrorCodes(t *testing.T) {
+	assert.Equal(t, GetErrorCode(Technical("x")), CodeTechnical)
+	assert.Equal(t, GetErrorCode(BadRequest("x")), CodeBadRequest)
+	assert.Equal(t, GetErrorCode(Unauthorized("x")), CodeUnauthorized)
+	assert.Equal(t, GetErrorCode(Forbidden("x")), CodeForbidden)
+	assert.Equal(t, GetErrorCode(NotFound("x")), CodeNotFound)
+	assert.Equal(t, GetErrorCode(Conflict("x")), CodeConflict)
+	assert.Equal(t, GetErrorCode(ServiceUnavailable("x")), CodeUnavailable)
+	assert.Equal(t, GetErrorCode(errors.New("x")), CodeTechnical)
+	assert.Equal(t, GetErrorCode(&CustomError{Code: http.StatusTooManyRequests}), "TOO_MANY_REQUESTS")
+}
+
+func TestNew_WithCodeDetailsAndCause(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := New(http.StatusConflict, "USER_EXISTS", "user already exists").
+		WithDetails(ErrorDetail{Field: "email", Tag: "unique", Message: "email is already taken"}).
+		WithCause(cause)
+
+	assert.Equal(t, err.Error(), "user already exists")
+	assert.Equal(t, GetStatusCode(err), http.StatusConflict)
+	assert.Equal(t, GetErrorCode(err), "USER_EXISTS")
+	assert.Equal(t, len(err.Details), 1)
+	assert.Equal(t, errors.Is(err, cause), true)
+}
+
+func TestValidation(t *testing.T) {
+	err := Validation(
+		ErrorDetail{Field: "name", Tag: "required", Message: "name is a required field"},
+		ErrorDetail{Field: "email", Tag: "email", Message: "email must be a valid email address"},
+	)
+
+	var ce *CustomError
+	assert.Equal(t, errors.As(err, &ce), true)
+	assert.Equal(t, ce.Code, http.StatusBadRequest)
+	assert.Equal(t, ce.ErrorCode, CodeValidationFailed)
+	assert.Equal(t, len(ce.Details), 2)
+	assert.Equal(t, ce.Details[0].Field, "name")
+}
@@ -1,11 +1,29 @@
 package log
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
 	log "github.com/sirupsen/logrus"
 )
 
 // log.Debugf("downloading invoice %s", input.ID)
 
+const (
+	FormatJSON   = "json"
+	FormatPretty = "pretty"
+	FormatText   = "text"
+)
+
+var (
+	mu         sync.RWMutex
+	root       = slog.New(&logrusHandler{})
+	extractors []func(ctx context.Context) []any
+)
+
 func Init(level string) {
 	logLevel, err := log.ParseLevel(level)
 	if err == nil {
@@ -15,26 +33,217 @@ func Init(level string) {
 	}
 }
 
+// SetFormat selects the output of the default handler: json for production,
+// pretty (colored, full timestamps) for development or plain text.
+func SetFormat(format string) {
+	switch format {
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	case FormatPretty:
+		log.SetFormatter(&log.TextFormatter{ForceColors: true, FullTimestamp: true})
+	default:
+		log.SetFormatter(&log.TextFormatter{})
+	}
+}
+
+// SetHandler routes every log record to a custom slog handler. Passing nil
+// restores the default logrus backed handler.
+func SetHandler(handler slog.Handler) {
+	if handler == nil {
+		handler = &logrusHandler{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	root = slog.New(handler)
+}
+
+// RegisterContextExtractor adds a function returning the key/value pairs that
+// FromContext attaches to the logger, e.g. the request or tenant id.
+func RegisterContextExtractor(extractor func(ctx context.Context) []any) {
+	mu.Lock()
+	defer mu.Unlock()
+	extractors = append(extractors, extractor)
+}
+
+func rootLogger() *slog.Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return root
+}
+
 func IsDebugEnabled() bool {
-	return log.GetLevel() == log.DebugLevel
+	return rootLogger().Enabled(context.Background(), slog.LevelDebug)
+}
+
+// logf formats the message only when level is enabled, debug logs being on hot paths
+func logf(level slog.Level, format string, args ...any) {
+	logger := rootLogger()
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, fmt.Sprintf(format, args...))
 }
 
 func Debug(format string, args ...any) {
-	log.Debugf(format, args...)
+	logf(slog.LevelDebug, format, args...)
 }
 
 func Info(format string, args ...any) {
-	log.Infof(format, args...)
+	logf(slog.LevelInfo, format, args...)
 }
 
 func Warn(format string, args ...any) {
-	log.Warnf(format, args...)
+	logf(slog.LevelWarn, format, args...)
 }
 
 func Error(format string, args ...any) {
-	log.Errorf(format, args...)
+	logf(slog.LevelError, format, args...)
 }
 
 func Fatal(format string, args ...any) {
-	log.Fatalf(format, args...)
+	logf(slog.LevelError, format, args...)
+	os.Exit(1)
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// STRUCTURED LOGGER
+// ------------------------------------------------------------------------------------------------------------------
+
+// Logger writes structured records: log.FromContext(ctx).With("invoice", id).Info("downloaded")
+type Logger struct {
+	ctx      context.Context
+	internal *slog.Logger
+}
+
+// FromContext returns a logger carrying the request id, tenant id, user id and
+// feature found in ctx.
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	mu.RLock()
+	registered := extractors
+	mu.RUnlock()
+	logger := rootLogger()
+	for _, extract := range registered {
+		if args := extract(ctx); len(args) > 0 {
+			logger = logger.With(args...)
+		}
+	}
+	return &Logger{ctx: ctx, internal: logger}
+}
+
+// With returns a logger including the given key/value pairs in every record
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{ctx: l.ctx, internal: l.internal.With(args...)}
+}
+
+func (l *Logger) Debug(msg string, args ...any) {
+	l.internal.DebugContext(l.ctx, msg, args...)
+}
+
+func (l *Logger) Info(msg string, args ...any) {
+	l.internal.InfoContext(l.ctx, msg, args...)
+}
+
+func (l *Logger) Warn(msg string, args ...any) {
+	l.internal.WarnContext(l.ctx, msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...any) {
+	l.internal.ErrorContext(l.ctx, msg, args...)
+}
+
+// Slog exposes the underlying slog logger
+func (l *Logger) Slog() *slog.Logger {
+	return l.internal
+}
+
+// ------------------------------------------------------------------------------------------------------------------
+// LOGRUS HANDLER
+// ------------------------------------------------------------------------------------------------------------------
+
+// logrusHandler is the default slog handler, it writes records through the logrus
+// standard logger so existing level and formatter settings keep applying.
+type logrusHandler struct {
+	attrs []slog.Attr
+	group string
+}
+
+func (h *logrusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return log.IsLevelEnabled(logrusLevel(level))
+}
+
+func (h *logrusHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := log.Fields{}
+	for _, attr := range h.attrs {
+		addField(fields, "", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addField(fields, h.group, attr)
+		return true
+	})
+	entry := log.NewEntry(log.StandardLogger())
+	if len(fields) > 0 {
+		entry = entry.WithFields(fields)
+	}
+	if !record.Time.IsZero() {
+		entry = entry.WithTime(record.Time)
+	}
+	entry.Log(logrusLevel(record.Level), record.Message)
+	return nil
+}
+
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &logrusHandler{group: h.group, attrs: append([]slog.Attr{}, h.attrs...)}
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		next.attrs = append(next.attrs, attr)
+	}
+	return next
+}
+
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &logrusHandler{group: group, attrs: h.attrs}
+}
+
+func addField(fields log.Fields, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, child := range attr.Value.Group() {
+			addField(fields, key, child)
+		}
+		return
+	}
+	fields[key] = attr.Value.Any()
+}
+
+func logrusLevel(level slog.Level) log.Level {
+	switch {
+	case level >= slog.LevelError:
+		return log.ErrorLevel
+	case level >= slog.LevelWarn:
+		return log.WarnLevel
+	case level >= slog.LevelInfo:
+		return log.InfoLevel
+	default:
+		return log.DebugLevel
+	}
 }
@@ -23,8 +23,11 @@ type Helper struct {
 	Assert     Assertions
 	openFiles  []string
 	rootDir    string
+	// container is the container created by Build, cleared on TearDown
+	container *f.Container
 }
 
+// New serves app for the test, the container of app is left to the caller
 func New(app f.App, t *testing.T) *Helper {
 
 	// Create a test server
@@ -42,12 +45,31 @@ func New(app f.App, t *testing.T) *Helper {
 	}
 }
 
+// Build creates a container, builds the app under test in it and serves it. build
+// usually returns app.New(...).WithContainer(container).MustInit(features). The helper
+// owns the container and clears it on TearDown so that tests can run with t.Parallel(),
+// they resolve their components from Container() rather than the package level functions.
+func Build(t *testing.T, build func(container *f.Container) f.App) *Helper {
+	container := f.NewContainer()
+	helper := New(build(container), t)
+	helper.container = container
+	return helper
+}
+
+// Container returns the components of the application under test
+func (t *Helper) Container() *f.Container {
+	return t.app.Container()
+}
+
 func (t *Helper) FilePath(p string) string {
 	return path.Join(t.rootDir, p)
 }
 
 func (t *Helper) TearDown() {
 	t.app.Shutdown(context.Background())
+	if t.container != nil {
+		t.container.Clear()
+	}
 	for _, file := range t.openFiles {
 		log.Info("removing db file: %s", file)
 		_ = os.Remove(file)
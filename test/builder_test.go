@@ -0,0 +1,62 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/soffa-projects/foundation-go/app"
+	f "github.com/soffa-projects/foundation-go/core"
+)
+
+type greeter struct {
+	greeting string
+}
+
+// greeterFeature uses the package level functions, like the features written before
+// the apps had containers of their own
+func greeterFeature(greeting string) f.Feature {
+	return f.Feature{
+		Name: "greeter",
+		OnInit: func(c f.InitContext) {
+			f.Provide(&greeter{greeting: greeting})
+			c.Router.GET("/greeting", func(ctx f.HttpContext) error {
+				g := f.Lookup[*greeter]()
+				if g == nil {
+					return ctx.JSON(http.StatusInternalServerError, "greeter not found")
+				}
+				return ctx.JSON(http.StatusOK, (*g).greeting)
+			})
+		},
+	}
+}
+
+func TestHelper_PackageLevelFunctionsResolveFromTheApp(t *testing.T) {
+	helper := New(app.New("greeter", "1.0", "test").MustInit([]f.Feature{greeterFeature("hello")}), t)
+	defer helper.TearDown()
+
+	helper.Assert.True(f.DefaultContainer() == helper.Container())
+	// the components of the app are registered in its container
+	helper.Assert.Equals(f.Lookup[f.AppInfo]().Name, "greeter")
+	helper.Assert.Equals(strings.TrimSpace(string(helper.Http.Get("/greeting").IsOk().Result())), `"hello"`)
+}
+
+func TestHelper_BuildOwnsItsContainer(t *testing.T) {
+	shared := f.NewContainer()
+	f.ProvideIn(shared, &greeter{greeting: "shared"})
+
+	var container *f.Container
+	helper := Build(t, func(c *f.Container) f.App {
+		container = c
+		return app.New("greeter", "1.0", "test").WithContainer(c).MustInit([]f.Feature{greeterFeature("hello")})
+	})
+	helper.Assert.True(helper.Container() == container)
+	helper.Assert.True(f.LookupIn[*greeter](container) != nil)
+	helper.TearDown()
+
+	helper.Assert.True(f.LookupIn[*greeter](container) == nil)
+	// containers supplied by the caller are never cleared
+	plain := New(app.New("greeter", "1.0", "test").WithContainer(shared).MustInit([]f.Feature{greeterFeature("hello")}), t)
+	plain.TearDown()
+	helper.Assert.True(f.LookupIn[*greeter](shared) != nil)
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/go-faker/faker/v4"
 	"github.com/soffa-projects/foundation-go/h"
@@ -43,6 +44,19 @@ func TestDatabaseURL() string {
 	return fmt.Sprintf("file:%s?mode=memory&cache=shared", h.RandomString(5))
 }
 
+// TestDatabaseURLs returns the url of an in-memory sqlite database followed by the urls of
+// the databases set in TEST_POSTGRES_URL and TEST_MYSQL_URL, to run a test against every
+// available dialect.
+func TestDatabaseURLs() []string {
+	urls := []string{TestDatabaseURL()}
+	for _, env := range []string{"TEST_POSTGRES_URL", "TEST_MYSQL_URL"} {
+		if databaseUrl := os.Getenv(env); databaseUrl != "" {
+			urls = append(urls, databaseUrl)
+		}
+	}
+	return urls
+}
+
 func FakePubSubProvider() string {
 	return "faker://pubsub"
 }